@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/filecoin-project/specs-actors/v5/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 批量转账中的一行
+type payoutRow struct {
+	Row    int
+	To     address.Address
+	Amount abi.TokenAmount
+	Memo   string
+
+	Msg      *types.Message
+	Cid      cid.Cid
+	Status   string
+	ExitCode int64
+}
+
+var sendBatchCmd = &cli.Command{
+	Name:      "send-batch",
+	Usage:     "从CSV或JSON文件批量转账，所有转账使用连续的nonce，只需确认一次",
+	ArgsUsage: "<file.csv|file.json>",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "from",
+			Usage:    "转账源账户",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "result",
+			Usage: "结果文件路径，默认为 <file>.result.csv",
+		},
+		yesFlag,
+		confidenceFlag,
+	}, gasFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定转账文件")
+			return fmt.Errorf("must pass payout file")
+		}
+
+		from, err := address.NewFromString(cctx.String("from"))
+		if err != nil {
+			fmt.Printf("解析转账源地址失败: %v\n", err)
+			return err
+		}
+
+		// 1. 校验所有行
+		in := cctx.Args().First()
+		rows, err := readPayoutFile(in, from)
+		if err != nil {
+			fmt.Printf("转账文件(%s)校验失败: %v\n", in, err)
+			return err
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		// 2. 使用连续的nonce评估每一笔转账的gas
+		nonce, err := nextNonce(ctx, api, from)
+		if err != nil {
+			fmt.Printf("获取nonce失败，err:%v\n", err)
+			return err
+		}
+
+		total, totalFee := big.Zero(), big.Zero()
+		for _, r := range rows {
+			msg, err := estimateMessageGas(cctx, api, &types.Message{
+				From:   from,
+				To:     r.To,
+				Value:  r.Amount,
+				Method: builtin.MethodSend,
+				Nonce:  nonce,
+			})
+			if err != nil {
+				fmt.Printf("第%d行评估gas失败\n", r.Row)
+				return err
+			}
+			nonce++
+
+			r.Msg = msg
+			total = big.Add(total, r.Amount)
+			totalFee = big.Add(totalFee, big.Mul(msg.GasFeeCap, big.NewInt(msg.GasLimit)))
+		}
+
+		balance, err := api.WalletBalance(ctx, from)
+		if err != nil {
+			fmt.Printf("读取余额失败，err:%v\n", err)
+			return err
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Row"),
+			tablewriter.Col("To"),
+			tablewriter.Col("Amount"),
+			tablewriter.Col("Nonce"),
+			tablewriter.Col("MaxFee"),
+			tablewriter.Col("Memo"))
+		for _, r := range rows {
+			tw.Write(map[string]interface{}{
+				"Row":    r.Row,
+				"To":     r.To,
+				"Amount": types.FIL(r.Amount),
+				"Nonce":  r.Msg.Nonce,
+				"MaxFee": types.FIL(big.Mul(r.Msg.GasFeeCap, big.NewInt(r.Msg.GasLimit))),
+				"Memo":   r.Memo,
+			})
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+
+		fmt.Printf("共 %d 笔，转账总额: %s，最大手续费: %s，账户余额: %s\n",
+			len(rows), types.FIL(total), types.FIL(totalFee), types.FIL(balance))
+
+		if balance.LessThan(big.Add(total, totalFee)) {
+			fmt.Println("账户余额不足")
+			return xerrors.Errorf("insufficient balance: %s < %s", types.FIL(balance), types.FIL(big.Add(total, totalFee)))
+		}
+
+		if cctx.Bool("dry-run") {
+			for _, r := range rows {
+				fmt.Printf("\n第%d行:\n", r.Row)
+				if err := dryRunMessage(cctx, api, r.Msg); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		// 3. 发送第一笔之前检查所有转账是否满足转账策略
+		msgs := make([]*types.Message, len(rows))
+		for i, r := range rows {
+			msgs[i] = r.Msg
+		}
+		if err := enforceBatchPolicy(ctx, api, msgs, func(i int) string {
+			return fmt.Sprintf("第%d行", rows[i].Row)
+		}); err != nil {
+			return err
+		}
+
+		// 4. 确认后签名推送
+		if !cctx.Bool("yes") {
+			fmt.Print("确认发送请输入 yes: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(line) != "yes" {
+				fmt.Println("已取消")
+				return nil
+			}
+		}
+
+		result := cctx.String("result")
+		if result == "" {
+			result = strings.TrimSuffix(in, filepath.Ext(in)) + ".result.csv"
+		}
+
+		var pushErr error
+		for _, r := range rows {
+			sm, err := signChainMessage(ctx, api, r.Msg)
+			if err == nil {
+				r.Cid, err = pushMessage(cctx, api, sm)
+			}
+			if err != nil {
+				// 后续消息的nonce不再连续，停止推送
+				fmt.Printf("第%d行推送失败，停止推送，err:%v\n", r.Row, err)
+				r.Status = "push failed: " + err.Error()
+				pushErr = err
+				break
+			}
+
+			r.Status = "pushed"
+			fmt.Printf("第%d行 -> %s: %s\n", r.Row, r.To, r.Cid)
+		}
+
+		// 先写一次结果，避免等待过程中中断丢失CID
+		if err := writePayoutResult(result, rows); err != nil {
+			fmt.Printf("写入结果文件失败，err:%v\n", err)
+			return err
+		}
+
+		// 5. 等待所有消息上链
+		var failed int
+		for _, r := range rows {
+			if !r.Cid.Defined() {
+				continue
+			}
+
+			wait, err := api.StateWaitMsg(ctx, r.Cid, cctx.Uint64("confidence"))
+			if err != nil {
+				r.Status = "wait failed: " + err.Error()
+				failed++
+				continue
+			}
+
+			r.Cid = wait.Message
+			r.ExitCode = int64(wait.Receipt.ExitCode)
+			if wait.Receipt.ExitCode == 0 {
+				r.Status = "ok"
+			} else {
+				r.Status = "failed"
+				failed++
+			}
+		}
+
+		if err := writePayoutResult(result, rows); err != nil {
+			fmt.Printf("写入结果文件失败，err:%v\n", err)
+			return err
+		}
+		fmt.Println("结果已写入", result)
+
+		if pushErr != nil {
+			return pushErr
+		}
+		if failed > 0 {
+			return xerrors.Errorf("%d payouts failed", failed)
+		}
+		return nil
+	},
+}
+
+// 读取并校验转账文件。CSV格式每行为 to,amount[,memo]，可以有表头；JSON格式为 [{"To","Amount","Memo"}]。
+// 行号为CSV文件中的行号（包括表头），JSON为数组中的序号，从1开始
+func readPayoutFile(path string, from address.Address) ([]*payoutRow, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每条记录对应的行号
+	var records [][]string
+	var lines []int
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var items []struct {
+			To     string
+			Amount string
+			Memo   string
+		}
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		for i, it := range items {
+			records = append(records, []string{it.To, it.Amount, it.Memo})
+			lines = append(lines, i+1)
+		}
+	} else {
+		// 行号取记录开始的行，与文件一致（表头、空行和引号中的换行都计入行号）
+		r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if pe, ok := err.(*csv.ParseError); ok {
+					return nil, xerrors.Errorf("row %d: %w", pe.StartLine, pe.Err)
+				}
+				return nil, err
+			}
+
+			line, _ := r.FieldPos(0)
+			if len(records) == 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "to") {
+				continue
+			}
+			records = append(records, rec)
+			lines = append(lines, line)
+		}
+	}
+
+	var rows []*payoutRow
+	var errs []string
+	for i, rec := range records {
+		n := lines[i]
+		if len(rec) < 2 {
+			errs = append(errs, fmt.Sprintf("row %d: expected to,amount", n))
+			continue
+		}
+
+		to, err := address.NewFromString(strings.TrimSpace(rec[0]))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: invalid address %q: %s", n, rec[0], err))
+			continue
+		}
+		if to == from {
+			errs = append(errs, fmt.Sprintf("row %d: sending to source address", n))
+			continue
+		}
+
+		amount, err := types.ParseFIL(strings.TrimSpace(rec[1]))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: invalid amount %q: %s", n, rec[1], err))
+			continue
+		}
+		if !abi.TokenAmount(amount).GreaterThan(big.Zero()) {
+			errs = append(errs, fmt.Sprintf("row %d: amount must be positive", n))
+			continue
+		}
+
+		row := &payoutRow{Row: n, To: to, Amount: abi.TokenAmount(amount)}
+		if len(rec) > 2 {
+			row.Memo = rec[2]
+		}
+		rows = append(rows, row)
+	}
+
+	if len(errs) > 0 {
+		return nil, xerrors.New(strings.Join(errs, "\n"))
+	}
+	if len(rows) == 0 {
+		return nil, xerrors.New("no payouts in file")
+	}
+	return rows, nil
+}
+
+func writePayoutResult(path string, rows []*payoutRow) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"row", "to", "amount", "memo", "nonce", "cid", "status", "exit_code"}); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		var c string
+		if r.Cid.Defined() {
+			c = r.Cid.String()
+		}
+		status := r.Status
+		if status == "" {
+			status = "not sent"
+		}
+		if err := w.Write([]string{
+			strconv.Itoa(r.Row),
+			r.To.String(),
+			types.FIL(r.Amount).Unitless(),
+			r.Memo,
+			strconv.FormatUint(r.Msg.Nonce, 10),
+			c,
+			status,
+			strconv.FormatInt(r.ExitCode, 10),
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
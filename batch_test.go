@@ -0,0 +1,130 @@
+package main
+
+import (
+	"github.com/filecoin-project/lotus/chain/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPayoutFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ff-wallet-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	from := testAddress(t, "f1os47z7tbjpqw3kt6k77xzmtly5uy4f7nr7k64fi")
+	a := "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy"
+	b := "f1ys5qqiciehcml3sp764ymbbytfn3qoar5fo3iwy"
+
+	tests := []struct {
+		name string
+		data string
+		rows []int
+		// 错误信息中应包含的内容，为空时不应出错
+		errs []string
+	}{
+		{"header", "to,amount,memo\n" + a + ",10,alice\n" + b + ",2.5,bob\n", []int{2, 3}, nil},
+		{"no header", a + ",1\r\n" + b + ",2\r\n", []int{1, 2}, nil},
+		{"blank lines", "to,amount\n\n" + a + ",1\n\n" + b + ",2", []int{3, 5}, nil},
+		{"bad rows", "to,amount\n" + a + ",1\n" + "f1bad,1\n" + b + ",-1\n" + from.String() + ",1\n" + a + "\n",
+			nil, []string{"row 3: invalid address", "row 4: amount must be positive", "row 5: sending to source", "row 6: expected to,amount"}},
+		{"bad amount", a + ",x\n", nil, []string{"row 1: invalid amount"}},
+		// 引号中的换行和逗号，行号为记录开始的行
+		{"quoted", "to,amount,memo\n" + a + ",1,\"line1\nline2, more\"\n" + b + ",2,bob\n", []int{2, 4}, nil},
+		{"quoted bad", "to,amount,memo\n" + a + ",1,\"a\nb\"\n" + b + ",x\n", nil, []string{"row 4: invalid amount"}},
+		{"bom", "\ufeffto,amount\n" + a + ",1\n", []int{2}, nil},
+		{"unterminated quote", "to,amount,memo\n" + a + ",1,\"memo\n", nil, []string{"row 2:"}},
+		{"empty", "to,amount\n", nil, []string{"no payouts"}},
+		{"json", `[{"To": "` + a + `", "Amount": "1", "Memo": "m"}, {"To": "` + b + `", "Amount": "0.5"}]`, []int{1, 2}, nil},
+		{"json bad", `[{"To": "` + a + `", "Amount": "1"}, {"To": "` + a + `", "Amount": "0"}]`, nil, []string{"row 2: amount must be positive"}},
+	}
+
+	for _, tc := range tests {
+		ext := ".csv"
+		if strings.HasPrefix(tc.name, "json") {
+			ext = ".json"
+		}
+		path := filepath.Join(dir, strings.Replace(tc.name, " ", "-", -1)+ext)
+		if err := ioutil.WriteFile(path, []byte(tc.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := readPayoutFile(path, from)
+		if len(tc.errs) > 0 {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.name)
+				continue
+			}
+			for _, e := range tc.errs {
+				if !strings.Contains(err.Error(), e) {
+					t.Errorf("%s: error %q does not contain %q", tc.name, err, e)
+				}
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+
+		var got []int
+		for _, r := range rows {
+			got = append(got, r.Row)
+		}
+		if len(got) != len(tc.rows) {
+			t.Errorf("%s: rows %v, want %v", tc.name, got, tc.rows)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.rows[i] {
+				t.Errorf("%s: rows %v, want %v", tc.name, got, tc.rows)
+				break
+			}
+		}
+	}
+}
+
+func TestSendBatchPolicy(t *testing.T) {
+	from := setupTestWallet(t)
+	a := "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy"
+	b := "f1ys5qqiciehcml3sp764ymbbytfn3qoar5fo3iwy"
+
+	node := newTestNode()
+	node.addAccount(from, testAddress(t, "f01000"), types.FromFil(100))
+
+	dir, err := ioutil.TempDir("", "ff-wallet-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	path := filepath.Join(dir, "payouts.csv")
+	if err := ioutil.WriteFile(path, []byte("to,amount\n"+a+",3\n"+b+",3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每一行都在额度内，但合计超过额度，第一笔也不能发送
+	if err := savePolicy(&spendPolicy{AddressLimits: map[string]string{from.String(): "5"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := runTestCommand(node, sendBatchCmd, "--yes", "--from", from.String(), path); err == nil {
+		t.Fatal("expected policy violation")
+	}
+	if len(node.pushed) != 0 {
+		t.Fatalf("%d messages pushed despite policy violation", len(node.pushed))
+	}
+
+	if err := savePolicy(&spendPolicy{AddressLimits: map[string]string{from.String(): "6"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := runTestCommand(node, sendBatchCmd, "--yes", "--from", from.String(), path); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.pushed) != 2 {
+		t.Fatalf("expected 2 pushed messages, got %d", len(node.pushed))
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/filecoin-project/firefly-wallet/mnemonic"
+	"github.com/howeyc/gopass"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"strings"
+)
+
+const bundleFormat = "hex-lotus"
+
+var recipientKeygenCmd = &cli.Command{
+	Name:  "recipient-keygen",
+	Usage: "生成用于接收加密私钥文件的密钥对，公钥交给导出方使用 export-address --encrypt --recipient",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "私钥保存路径",
+			Value: "ff-wallet-identity.txt",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		identity, recipient, err := mnemonic.GenerateIdentity()
+		if err != nil {
+			fmt.Printf("生成密钥失败，err: %v\n", err)
+			return err
+		}
+
+		content := fmt.Sprintf("# public key: %s\n%s\n", recipient, identity)
+		if err := ioutil.WriteFile(cctx.String("output"), []byte(content), 0600); err != nil {
+			fmt.Printf("保存私钥文件失败，err: %v\n", err)
+			return err
+		}
+
+		fmt.Printf("私钥已保存到 %s，请妥善保管\n", cctx.String("output"))
+		fmt.Println("公钥:", recipient)
+		return nil
+	},
+}
+
+// 加密私钥并写入文件
+func writeKeyBundle(cctx *cli.Context, address, privKey string) error {
+	recipients := cctx.StringSlice("recipient")
+
+	var passphrase []byte
+	if len(recipients) == 0 {
+		var err error
+		passphrase, err = getBundlePassphrase(true)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := mnemonic.SealBundle(address, bundleFormat, []byte(privKey), passphrase, recipients)
+	if err != nil {
+		fmt.Printf("加密私钥失败，err: %v\n", err)
+		return err
+	}
+
+	out := cctx.String("output")
+	if out == "" {
+		out = address + ".ffkey"
+	}
+
+	if err := ioutil.WriteFile(out, data, 0600); err != nil {
+		fmt.Printf("保存加密文件失败，err: %v\n", err)
+		return err
+	}
+
+	fmt.Printf("加密私钥已保存到 %s\n", out)
+	return nil
+}
+
+// 解密export-address --encrypt导出的文件
+func openKeyBundle(cctx *cli.Context, data []byte) (*mnemonic.Bundle, []byte, error) {
+	b, err := mnemonic.ParseBundle(data)
+	if err != nil {
+		fmt.Printf("解析加密文件失败，err: %v\n", err)
+		return nil, nil, err
+	}
+
+	var identities []string
+	for _, path := range cctx.StringSlice("identity") {
+		ids, err := readIdentityFile(path)
+		if err != nil {
+			fmt.Printf("读取私钥文件 %s 失败，err: %v\n", path, err)
+			return nil, nil, err
+		}
+		identities = append(identities, ids...)
+	}
+
+	var passphrase []byte
+	if len(identities) == 0 {
+		if b.Passphrase == nil {
+			fmt.Println("该文件只能使用接收方私钥解密，请指定 --identity")
+			return nil, nil, xerrors.New("bundle requires --identity")
+		}
+		passphrase, err = getBundlePassphrase(false)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	plain, err := mnemonic.OpenBundle(b, passphrase, identities)
+	if err != nil {
+		fmt.Printf("解密失败，口令或私钥不正确，err: %v\n", err)
+		return nil, nil, err
+	}
+
+	return b, plain, nil
+}
+
+func readIdentityFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	return ids, nil
+}
+
+func getBundlePassphrase(confirm bool) ([]byte, error) {
+	fmt.Print("请输入加密文件口令(长度至少6位):")
+	pass, err := gopass.GetPasswdMasked()
+	if err != nil {
+		fmt.Printf("输入口令异常，%v\n", err)
+		return nil, err
+	}
+	if len(pass) < 6 {
+		fmt.Println("输入口令不合法: 长度太短")
+		return nil, xerrors.New("passphrase too short")
+	}
+
+	if confirm {
+		fmt.Print("请再次输入口令：")
+		passRe, err := gopass.GetPasswdMasked()
+		if err != nil {
+			fmt.Printf("输入口令异常，%v\n", err)
+			return nil, err
+		}
+		if !bytes.Equal(pass, passRe) {
+			fmt.Println("两次输入口令不一致")
+			return nil, xerrors.New("passphrases do not match")
+		}
+	}
+
+	return pass, nil
+}
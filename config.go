@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// 所有发送消息命令默认的最大手续费(FIL)
+	configMaxFee = "max-fee"
+)
+
+// 支持的配置项及其校验
+var configValidators = map[string]func(string) error{
+	configMaxFee: func(v string) error {
+		_, err := types.ParseFIL(v)
+		return err
+	},
+}
+
+// 读取配置项，钱包数据库不存在或者未设置时返回空字符串
+func getConfig(key string) (string, error) {
+	if localdb == nil {
+		if _, err := os.Stat(filepath.Join(getRepoPath(), "db")); err != nil {
+			return "", nil
+		}
+		if err := _initDb(); err != nil {
+			return "", err
+		}
+	}
+
+	v, err := localdb.Get(db.KeyConfig, key)
+	if err != nil {
+		if err == errors.ErrNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(v), nil
+}
+
+var configCmd = &cli.Command{
+	Name:  "config",
+	Usage: "查看和修改钱包配置",
+	Subcommands: []*cli.Command{
+		configSetCmd,
+		configListCmd,
+	},
+}
+
+var configSetCmd = &cli.Command{
+	Name:      "set",
+	Usage:     "修改配置，例如 config set max-fee 0.5，值为空时删除配置",
+	ArgsUsage: "<key> [value]",
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		key := cctx.Args().First()
+		validate, ok := configValidators[key]
+		if !ok {
+			fmt.Println("不支持的配置项:", key)
+			return xerrors.Errorf("unknown config key: %s", key)
+		}
+
+		value := cctx.Args().Get(1)
+		if value == "" {
+			if err := localdb.Del(db.KeyConfig, key); err != nil {
+				fmt.Printf("删除配置失败，err: %v\n", err)
+				return err
+			}
+			fmt.Println("已删除配置:", key)
+			return nil
+		}
+
+		if err := validate(value); err != nil {
+			fmt.Printf("配置值(%s)不合法，err: %v\n", value, err)
+			return err
+		}
+
+		if err := localdb.Add(db.KeyConfig, key, []byte(value)); err != nil {
+			fmt.Printf("保存配置失败，err: %v\n", err)
+			return err
+		}
+
+		fmt.Printf("%s = %s\n", key, value)
+		return nil
+	},
+}
+
+var configListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "展示所有配置",
+	Action: func(cctx *cli.Context) error {
+		var keys []string
+		for k := range configValidators {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		tw := tablewriter.New(
+			tablewriter.Col("Key"),
+			tablewriter.Col("Value"))
+		for _, k := range keys {
+			v, err := getConfig(k)
+			if err != nil {
+				return err
+			}
+			if v == "" {
+				v = "-"
+			}
+			tw.Write(map[string]interface{}{
+				"Key":   k,
+				"Value": v,
+			})
+		}
+		return tw.Flush(os.Stdout)
+	},
+}
@@ -33,6 +33,28 @@ func (lb *LocalDb) Del(keytype KeyType, key string) error {
 	return lb.db.Delete([]byte(lb.getKey(keytype, key)), nil)
 }
 
+// Batch 批量写入，Commit时所有记录一次性原子写入
+type Batch struct {
+	lb    *LocalDb
+	batch *leveldb.Batch
+}
+
+func (lb *LocalDb) NewBatch() *Batch {
+	return &Batch{lb: lb, batch: new(leveldb.Batch)}
+}
+
+func (b *Batch) Add(keytype KeyType, key string, value []byte) {
+	b.batch.Put([]byte(b.lb.getKey(keytype, key)), value)
+}
+
+func (b *Batch) Del(keytype KeyType, key string) {
+	b.batch.Delete([]byte(b.lb.getKey(keytype, key)))
+}
+
+func (b *Batch) Commit() error {
+	return b.lb.db.Write(b.batch, nil)
+}
+
 type KeyType string
 
 func (k KeyType) String() string {
@@ -44,6 +66,11 @@ const (
 	KeyIndex  KeyType = "filIndex"
 	KeyCommon KeyType = "commonKey"
 	KeyPriKey KeyType = "filPriKey"
+	KeyConfig KeyType = "config"
+	KeyNonce  KeyType = "nonce"
+	KeyOutbox KeyType = "outbox"
+	KeyPolicy KeyType = "policy"
+	KeySpend  KeyType = "spend"
 )
 
 func (lb *LocalDb) getKey(keyType KeyType, key string) string {
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/urfave/cli/v2"
+	"os"
+	"sort"
+)
+
+var dryRunFlag = &cli.BoolFlag{
+	Name:  "dry-run",
+	Usage: "只在当前链头模拟执行评估后的消息，输出执行结果，不签名也不推送",
+}
+
+// 在当前链头模拟执行消息，输出exit code、返回值、gas用量和余额变化
+func dryRunMessage(cctx *cli.Context, api v0api.FullNode, msg *types.Message) error {
+	ctx := lcli.ReqContext(cctx)
+
+	res, err := api.StateCall(ctx, msg, types.EmptyTSK)
+	if err != nil {
+		fmt.Printf("模拟执行消息失败，err:%v\n", err)
+		return err
+	}
+
+	fmt.Println("模拟执行结果(未签名、未推送):")
+	fmt.Println("ExitCode:", res.MsgRct.ExitCode)
+	if res.Error != "" {
+		fmt.Println("Error:", res.Error)
+	}
+	if len(res.MsgRct.Return) > 0 {
+		fmt.Println("Return:", hex.EncodeToString(res.MsgRct.Return))
+	}
+	fmt.Println("GasUsed:", res.MsgRct.GasUsed)
+	fmt.Println("最大手续费:", types.FIL(big.Mul(msg.GasFeeCap, big.NewInt(msg.GasLimit))))
+
+	changes := map[address.Address]abi.TokenAmount{}
+	collectTransfers(res.ExecutionTrace, changes)
+
+	// 发送方和接收方总是显示
+	for _, a := range []address.Address{msg.From, msg.To} {
+		if _, ok := changes[a]; !ok {
+			changes[a] = big.Zero()
+		}
+	}
+
+	return printBalanceChanges(ctx, api, changes)
+}
+
+// 统计执行成功的调用中的转账，失败调用及其子调用的转账会被回滚
+func collectTransfers(et types.ExecutionTrace, changes map[address.Address]abi.TokenAmount) {
+	if et.MsgRct == nil || et.MsgRct.ExitCode != 0 {
+		return
+	}
+
+	if et.Msg != nil && !et.Msg.Value.Nil() && !et.Msg.Value.IsZero() {
+		add := func(a address.Address, v abi.TokenAmount) {
+			if old, ok := changes[a]; ok {
+				v = big.Add(old, v)
+			}
+			changes[a] = v
+		}
+		add(et.Msg.From, big.Sub(big.Zero(), et.Msg.Value))
+		add(et.Msg.To, et.Msg.Value)
+	}
+
+	for _, sub := range et.Subcalls {
+		collectTransfers(sub, changes)
+	}
+}
+
+func printBalanceChanges(ctx context.Context, api v0api.FullNode, changes map[address.Address]abi.TokenAmount) error {
+	// 同一账户可能同时以ID地址和公钥地址出现，按ID地址合并
+	merged := map[address.Address]abi.TokenAmount{}
+	names := map[address.Address]address.Address{}
+	for a, v := range changes {
+		id, err := api.StateLookupID(ctx, a, types.EmptyTSK)
+		if err != nil {
+			id = a
+		}
+		if old, ok := merged[id]; ok {
+			v = big.Add(old, v)
+		}
+		merged[id] = v
+		if _, ok := names[id]; !ok || a.Protocol() != address.ID {
+			names[id] = a
+		}
+	}
+
+	var ids []address.Address
+	for id := range merged {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	tw := tablewriter.New(
+		tablewriter.Col("Address"),
+		tablewriter.Col("Balance"),
+		tablewriter.Col("Change"))
+	for _, id := range ids {
+		row := map[string]interface{}{
+			"Address": names[id],
+			"Change":  types.FIL(merged[id]),
+		}
+		if act, err := api.StateGetActor(ctx, id, types.EmptyTSK); err == nil {
+			row["Balance"] = types.FIL(act.Balance)
+		} else {
+			row["Balance"] = "-"
+		}
+		tw.Write(row)
+	}
+	fmt.Println("余额变化(不含手续费):")
+	return tw.Flush(os.Stdout)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var maxFeeFlag = &cli.StringFlag{
+	Name:  "max-fee",
+	Usage: "最大手续费(FIL)，GasFeeCap*GasLimit超过该值时拒绝签名。不指定时使用 config set max-fee 设置的默认值",
+}
+
+// gas相关参数
+var gasFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "gas-premium",
+		Usage: "指定gas premium(attoFIL)，不指定时由节点评估",
+	},
+	&cli.StringFlag{
+		Name:  "gas-feecap",
+		Usage: "指定gas fee cap(attoFIL)，不指定时由节点评估",
+	},
+	&cli.Int64Flag{
+		Name:  "gas-limit",
+		Usage: "指定gas limit，不指定时由节点评估",
+	},
+	maxFeeFlag,
+}
+
+// 所有发送消息命令共用的参数
+var msgSendFlags = append([]cli.Flag{unsignedOutFlag}, gasFlags...)
+
+// 读取最大手续费，优先使用--max-fee，其次使用钱包配置，都未设置时返回0
+func getMaxFee(cctx *cli.Context) (abi.TokenAmount, error) {
+	v := cctx.String("max-fee")
+	if v == "" {
+		var err error
+		v, err = getConfig(configMaxFee)
+		if err != nil {
+			return big.Zero(), err
+		}
+	}
+	if v == "" {
+		return big.Zero(), nil
+	}
+
+	f, err := types.ParseFIL(v)
+	if err != nil {
+		return big.Zero(), xerrors.Errorf("parsing max fee: %w", err)
+	}
+	return abi.TokenAmount(f), nil
+}
+
+// 将命令行指定的gas参数设置到消息中，未指定的参数由节点评估
+func applyGasFlags(cctx *cli.Context, msg *types.Message) error {
+	if v := cctx.String("gas-premium"); v != "" {
+		premium, err := types.BigFromString(v)
+		if err != nil {
+			return xerrors.Errorf("parsing gas premium: %w", err)
+		}
+		msg.GasPremium = premium
+	}
+
+	if v := cctx.String("gas-feecap"); v != "" {
+		feeCap, err := types.BigFromString(v)
+		if err != nil {
+			return xerrors.Errorf("parsing gas fee cap: %w", err)
+		}
+		msg.GasFeeCap = feeCap
+	}
+
+	if cctx.IsSet("gas-limit") {
+		msg.GasLimit = cctx.Int64("gas-limit")
+	}
+
+	return nil
+}
+
+// 评估消息gas，使用命令行指定的gas参数和最大手续费
+func estimateMessageGas(cctx *cli.Context, api v0api.FullNode, msg *types.Message) (*types.Message, error) {
+	if err := applyGasFlags(cctx, msg); err != nil {
+		fmt.Printf("解析gas参数失败， err:%v\n", err)
+		return nil, err
+	}
+
+	maxFee, err := getMaxFee(cctx)
+	if err != nil {
+		fmt.Printf("读取最大手续费失败， err:%v\n", err)
+		return nil, err
+	}
+
+	// 不把最大手续费传给节点：节点会静默压低GasFeeCap，导致消息可能长时间无法上链，超过限制时由checkMaxFee拒绝签名
+	msg, err = api.GasEstimateMessageGas(lcli.ReqContext(cctx), msg, nil, types.EmptyTSK)
+	if err != nil {
+		fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
+		return nil, xerrors.Errorf("GasEstimateMessageGas error: %w", err)
+	}
+
+	if err := checkMaxFee(msg, maxFee); err != nil {
+		fmt.Printf("最大手续费超过限制，拒绝签名: %v\n", err)
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// 最大可能手续费超过限制时拒绝签名
+func checkMaxFee(msg *types.Message, maxFee abi.TokenAmount) error {
+	if maxFee.IsZero() {
+		required, err := policyRequiresMaxFee()
+		if err != nil {
+			return err
+		}
+		if required {
+			return xerrors.New("policy requires --max-fee or config max-fee")
+		}
+		return nil
+	}
+
+	fee := big.Mul(msg.GasFeeCap, big.NewInt(msg.GasLimit))
+	if fee.GreaterThan(maxFee) {
+		return xerrors.Errorf("max fee %s exceeds limit %s", types.FIL(fee), types.FIL(maxFee))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	"testing"
+)
+
+func TestCheckMaxFee(t *testing.T) {
+	setupTestWallet(t)
+
+	msg := &types.Message{GasLimit: 1000000, GasFeeCap: big.NewInt(100000)}
+	fee := big.Mul(msg.GasFeeCap, big.NewInt(msg.GasLimit))
+
+	tests := []struct {
+		maxFee  abi.TokenAmount
+		require bool
+		ok      bool
+	}{
+		{big.Zero(), false, true},
+		{fee, false, true},
+		{big.Add(fee, big.NewInt(1)), false, true},
+		{big.Sub(fee, big.NewInt(1)), false, false},
+		// 策略要求指定最大手续费
+		{big.Zero(), true, false},
+		{fee, true, true},
+	}
+
+	for _, tc := range tests {
+		if err := savePolicy(&spendPolicy{RequireMaxFee: tc.require}, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		err := checkMaxFee(msg, tc.maxFee)
+		if (err == nil) != tc.ok {
+			t.Errorf("max fee %s, require %v: ok=%v, err=%v", types.FIL(tc.maxFee), tc.require, tc.ok, err)
+		}
+	}
+}
+
+func TestEstimateRespectsMaxFee(t *testing.T) {
+	from := setupTestWallet(t)
+	to := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+
+	node := newTestNode()
+	node.addAccount(from, testAddress(t, "f01000"), types.FromFil(10))
+
+	// 节点评估的最大手续费为 100000*1000000 attoFIL = 0.0000001 FIL
+	args := []string{"--yes", "--from", from.String(), "--to", to.String(), "--amount", "1"}
+	if err := runTestCommand(node, sendCmd, append(args, "--max-fee", "0.00000001")...); err == nil {
+		t.Fatal("expected max fee error")
+	}
+	if len(node.pushed) != 0 {
+		t.Fatal("message exceeding max fee was pushed")
+	}
+
+	if err := runTestCommand(node, sendCmd, append(args, "--max-fee", "0.0000001")...); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.pushed) != 1 {
+		t.Fatal("message within max fee was not pushed")
+	}
+
+	// 最大手续费不传给节点，避免节点压低GasFeeCap
+	for i, spec := range node.estimated {
+		if spec != nil {
+			t.Errorf("estimate %d passed send spec %+v", i, spec)
+		}
+	}
+}
@@ -14,10 +14,13 @@ require (
 	github.com/filecoin-project/specs-actors/v2 v2.3.5
 	github.com/filecoin-project/specs-actors/v5 v5.0.4
 	github.com/howeyc/gopass v0.0.0-20190910152052-7cb4b85ec19c
+	github.com/ipfs/go-cid v0.1.0
+	github.com/ipfs/go-ipld-cbor v0.0.5
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/syndtr/goleveldb v1.0.1-0.20210305035536-64b5b1c73954
 	github.com/tyler-smith/go-bip39 v1.1.0
 	github.com/urfave/cli/v2 v2.3.0
+	github.com/whyrusleeping/cbor-gen v0.0.0-20210713220151-be142a5ae1a8
 	golang.org/x/crypto v0.0.0-20211209193657-4570a0811e8b
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1
 )
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/consensus/filcns"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 历史记录的日期格式
+const historyDateLayout = "2006-01-02"
+
+// 钱包地址的一条收支记录
+type historyRow struct {
+	Height            abi.ChainEpoch
+	Time              time.Time
+	Cid               string
+	Address           string
+	Label             string
+	Direction         string
+	Counterparty      string
+	CounterpartyLabel string
+	Method            string
+	Value             string
+	// 执行过程中该地址的实际余额变化（不含手续费），例如提现时矿工转入的金额
+	Change   string
+	GasCost  string
+	ExitCode int64
+}
+
+var historyCmd = &cli.Command{
+	Name:  "history",
+	Usage: "查询钱包地址在指定高度或日期范围内的收支记录，导出CSV或JSON",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "address",
+			Usage: "只查询指定地址，可指定多个，默认查询所有钱包地址",
+		},
+		&cli.Int64Flag{
+			Name:  "from-height",
+			Usage: "起始高度，默认为结束高度前一天",
+		},
+		&cli.Int64Flag{
+			Name:  "to-height",
+			Usage: "结束高度，默认为当前链头",
+		},
+		&cli.StringFlag{
+			Name:  "from-date",
+			Usage: "起始日期(YYYY-MM-DD，本地时间)，与--from-height二选一",
+		},
+		&cli.StringFlag{
+			Name:  "to-date",
+			Usage: "结束日期(YYYY-MM-DD，本地时间，包含当天)，与--to-height二选一",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "导出格式: csv, json",
+			Value: "csv",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "导出文件，默认输出到标准输出",
+		},
+	},
+	Before: func(context *cli.Context) error {
+		return _initDb()
+	},
+	Action: func(cctx *cli.Context) error {
+		if f := cctx.String("format"); f != "csv" && f != "json" {
+			fmt.Println("不支持的导出格式:", f)
+			return xerrors.Errorf("unknown format %s", f)
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		from, to, err := historyRange(cctx, api)
+		if err != nil {
+			fmt.Println("解析查询范围失败,", err)
+			return err
+		}
+
+		labels, err := addressLabels(ctx, api)
+		if err != nil {
+			fmt.Println("读取数据库获取钱包地址失败")
+			return err
+		}
+
+		var addrs []address.Address
+		if cctx.IsSet("address") {
+			for _, s := range cctx.StringSlice("address") {
+				a, err := address.NewFromString(s)
+				if err != nil {
+					fmt.Println("解析钱包地址失败,", err)
+					return err
+				}
+				addrs = append(addrs, a)
+			}
+		} else {
+			all, err := localdb.GetAll(db.KeyAddr)
+			if err != nil {
+				fmt.Println("读取数据库获取钱包地址失败")
+				return err
+			}
+			for k := range all {
+				if a, err := address.NewFromString(k); err == nil {
+					addrs = append(addrs, a)
+				}
+			}
+		}
+
+		end, err := api.ChainGetTipSetByHeight(ctx, to, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取高度%d的tipset失败，err:%v\n", to, err)
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "查询高度 %d - %d 的消息...\n", from, to)
+
+		h := &historyBuilder{
+			api:     api,
+			labels:  labels,
+			replays: map[cid.Cid]*lapi.InvocResult{},
+			times:   map[types.TipSetKey]time.Time{},
+			methods: map[address.Address]map[abi.MethodNum]string{},
+		}
+
+		var rows []*historyRow
+		for _, a := range addrs {
+			r, err := h.addressHistory(ctx, a, end.Key(), from)
+			if err != nil {
+				fmt.Printf("查询地址(%s)的消息失败，err:%v\n", a, err)
+				return err
+			}
+			rows = append(rows, r...)
+		}
+
+		sort.SliceStable(rows, func(i, j int) bool {
+			if rows[i].Height != rows[j].Height {
+				return rows[i].Height < rows[j].Height
+			}
+			return rows[i].Address < rows[j].Address
+		})
+
+		var out io.Writer = os.Stdout
+		if p := cctx.String("output"); p != "" {
+			f, err := os.Create(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close() //nolint:errcheck
+			out = f
+		}
+
+		if cctx.String("format") == "json" {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(rows)
+		}
+		return writeHistoryCSV(out, rows)
+	},
+}
+
+// 解析查询的高度范围，日期按创世区块时间换算为高度
+func historyRange(cctx *cli.Context, api v0api.FullNode) (abi.ChainEpoch, abi.ChainEpoch, error) {
+	ctx := lcli.ReqContext(cctx)
+
+	head, err := api.ChainHead(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var genesis time.Time
+	if cctx.IsSet("from-date") || cctx.IsSet("to-date") {
+		gen, err := api.ChainGetGenesis(ctx)
+		if err != nil {
+			return 0, 0, err
+		}
+		genesis = time.Unix(int64(gen.MinTimestamp()), 0)
+	}
+	dateHeight := func(s string) (abi.ChainEpoch, error) {
+		t, err := time.ParseInLocation(historyDateLayout, s, time.Local)
+		if err != nil {
+			return 0, err
+		}
+		return abi.ChainEpoch(t.Sub(genesis) / (time.Duration(build.BlockDelaySecs) * time.Second)), nil
+	}
+
+	to := head.Height()
+	switch {
+	case cctx.IsSet("to-height") && cctx.IsSet("to-date"):
+		return 0, 0, xerrors.New("--to-height and --to-date are mutually exclusive")
+	case cctx.IsSet("to-height"):
+		to = abi.ChainEpoch(cctx.Int64("to-height"))
+	case cctx.IsSet("to-date"):
+		// 包含结束日期当天
+		h, err := dateHeight(cctx.String("to-date"))
+		if err != nil {
+			return 0, 0, err
+		}
+		to = h + abi.ChainEpoch(24*60*60/build.BlockDelaySecs) - 1
+	}
+	if to > head.Height() {
+		to = head.Height()
+	}
+
+	from := to - abi.ChainEpoch(24*60*60/build.BlockDelaySecs)
+	switch {
+	case cctx.IsSet("from-height") && cctx.IsSet("from-date"):
+		return 0, 0, xerrors.New("--from-height and --from-date are mutually exclusive")
+	case cctx.IsSet("from-height"):
+		from = abi.ChainEpoch(cctx.Int64("from-height"))
+	case cctx.IsSet("from-date"):
+		if from, err = dateHeight(cctx.String("from-date")); err != nil {
+			return 0, 0, err
+		}
+	}
+	if from < 0 {
+		from = 0
+	}
+
+	if from > to {
+		return 0, 0, xerrors.Errorf("from height %d is after to height %d", from, to)
+	}
+	return from, to, nil
+}
+
+// 钱包地址的标签，同时以公钥地址和ID地址索引
+func addressLabels(ctx context.Context, api v0api.FullNode) (map[address.Address]string, error) {
+	all, err := localdb.GetAll(db.KeyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[address.Address]string{}
+	for _, v := range all {
+		fai := FilAddressInfo{}
+		if err := json.Unmarshal([]byte(v), &fai); err != nil || fai.Label == "" {
+			continue
+		}
+		a, err := address.NewFromString(fai.Address)
+		if err != nil {
+			continue
+		}
+		labels[a] = fai.Label
+		if id, err := api.StateLookupID(ctx, a, types.EmptyTSK); err == nil {
+			labels[id] = fai.Label
+		}
+	}
+	return labels, nil
+}
+
+type historyBuilder struct {
+	api    v0api.FullNode
+	labels map[address.Address]string
+
+	replays map[cid.Cid]*lapi.InvocResult
+	times   map[types.TipSetKey]time.Time
+	methods map[address.Address]map[abi.MethodNum]string
+}
+
+func (h *historyBuilder) addressHistory(ctx context.Context, addr address.Address, end types.TipSetKey, from abi.ChainEpoch) ([]*historyRow, error) {
+	// StateListMessages按地址精确匹配，需要同时查询公钥地址和ID地址
+	self := map[address.Address]struct{}{addr: {}}
+	matches := []*lapi.MessageMatch{{From: addr}, {To: addr}}
+	if id, err := h.api.StateLookupID(ctx, addr, types.EmptyTSK); err == nil && id != addr {
+		self[id] = struct{}{}
+		matches = append(matches, &lapi.MessageMatch{From: id}, &lapi.MessageMatch{To: id})
+	}
+
+	seen := map[cid.Cid]struct{}{}
+	var rows []*historyRow
+	for _, m := range matches {
+		cids, err := h.api.StateListMessages(ctx, m, end, from)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range cids {
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+
+			row, err := h.messageRow(ctx, c, addr, self)
+			if err != nil {
+				return nil, xerrors.Errorf("message %s: %w", c, err)
+			}
+			if row != nil {
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+func (h *historyBuilder) messageRow(ctx context.Context, c cid.Cid, addr address.Address, self map[address.Address]struct{}) (*historyRow, error) {
+	msg, err := h.api.ChainGetMessage(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup, err := h.api.StateSearchMsg(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if lookup == nil {
+		// 最新tipset中的消息还没有执行
+		return nil, nil
+	}
+
+	res, ok := h.replays[c]
+	if !ok {
+		if res, err = h.api.StateReplay(ctx, types.EmptyTSK, c); err != nil {
+			return nil, err
+		}
+		h.replays[c] = res
+	}
+
+	t, ok := h.times[lookup.TipSet]
+	if !ok {
+		ts, err := h.api.ChainGetTipSet(ctx, lookup.TipSet)
+		if err != nil {
+			return nil, err
+		}
+		t = time.Unix(int64(ts.MinTimestamp()), 0)
+		h.times[lookup.TipSet] = t
+	}
+
+	changes := map[address.Address]abi.TokenAmount{}
+	collectTransfers(res.ExecutionTrace, changes)
+	change := big.Zero()
+	for a, v := range changes {
+		if _, ok := self[a]; ok {
+			change = big.Add(change, v)
+		}
+	}
+
+	row := &historyRow{
+		Height:   lookup.Height,
+		Time:     t,
+		Cid:      c.String(),
+		Address:  addr.String(),
+		Label:    h.labels[addr],
+		Method:   h.methodName(ctx, msg.To, msg.Method),
+		Value:    types.FIL(msg.Value).Unitless(),
+		Change:   types.FIL(change).Unitless(),
+		GasCost:  "0",
+		ExitCode: int64(lookup.Receipt.ExitCode),
+	}
+
+	if _, ok := self[msg.From]; ok {
+		row.Direction = "out"
+		row.Counterparty = msg.To.String()
+		row.CounterpartyLabel = h.labels[msg.To]
+		row.GasCost = types.FIL(res.GasCost.TotalCost).Unitless()
+	} else {
+		row.Direction = "in"
+		row.Counterparty = msg.From.String()
+		row.CounterpartyLabel = h.labels[msg.From]
+	}
+
+	return row, nil
+}
+
+// 根据接收方actor类型返回方法名称，未知时返回方法编号
+func (h *historyBuilder) methodName(ctx context.Context, to address.Address, method abi.MethodNum) string {
+	names, ok := h.methods[to]
+	if !ok {
+		names = map[abi.MethodNum]string{}
+		if act, err := h.api.StateGetActor(ctx, to, types.EmptyTSK); err == nil {
+			for num, meta := range filcns.NewActorRegistry().Methods[act.Code] {
+				names[num] = meta.Name
+			}
+		}
+		h.methods[to] = names
+	}
+
+	if name, ok := names[method]; ok {
+		return name
+	}
+	if method == 0 {
+		return "Send"
+	}
+	return strconv.FormatUint(uint64(method), 10)
+}
+
+func writeHistoryCSV(out io.Writer, rows []*historyRow) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"height", "time", "cid", "address", "label", "direction", "counterparty",
+		"counterparty_label", "method", "value", "change", "gas_cost", "exit_code"}); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := w.Write([]string{
+			strconv.FormatInt(int64(r.Height), 10),
+			r.Time.Format(time.RFC3339),
+			r.Cid,
+			r.Address,
+			r.Label,
+			r.Direction,
+			r.Counterparty,
+			r.CounterpartyLabel,
+			r.Method,
+			r.Value,
+			r.Change,
+			r.GasCost,
+			strconv.FormatInt(r.ExitCode, 10),
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
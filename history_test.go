@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/urfave/cli/v2"
+	"testing"
+	"time"
+)
+
+func TestHistoryRange(t *testing.T) {
+	node := newTestNode()
+	node.genesis = uint64(time.Date(2020, 10, 15, 0, 0, 0, 0, time.Local).Unix())
+	node.heights = []abi.ChainEpoch{10000}
+
+	// 30秒出块，每天2880个高度
+	tests := []struct {
+		args     []string
+		from, to abi.ChainEpoch
+		ok       bool
+	}{
+		{nil, 10000 - 2880, 10000, true},
+		{[]string{"--from-height", "100", "--to-height", "200"}, 100, 200, true},
+		{[]string{"--to-height", "20000"}, 10000 - 2880, 10000, true},
+		{[]string{"--to-height", "1000"}, 0, 1000, true},
+		{[]string{"--from-date", "2020-10-16", "--to-date", "2020-10-16"}, 2880, 5759, true},
+		{[]string{"--from-date", "2020-10-16"}, 2880, 10000, true},
+		{[]string{"--from-height", "300", "--to-height", "200"}, 0, 0, false},
+		{[]string{"--from-height", "1", "--from-date", "2020-10-16"}, 0, 0, false},
+		{[]string{"--to-height", "1", "--to-date", "2020-10-16"}, 0, 0, false},
+		{[]string{"--from-date", "16/10/2020"}, 0, 0, false},
+	}
+
+	for _, tc := range tests {
+		var from, to abi.ChainEpoch
+		err := runTestFlags(node, historyCmd.Flags, func(cctx *cli.Context) error {
+			var err error
+			from, to, err = historyRange(cctx, v0Node(node))
+			return err
+		}, tc.args...)
+		if (err == nil) != tc.ok {
+			t.Errorf("%v: ok=%v, err=%v", tc.args, tc.ok, err)
+			continue
+		}
+		if tc.ok && (from != tc.from || to != tc.to) {
+			t.Errorf("%v: got %d-%d, want %d-%d", tc.args, from, to, tc.from, tc.to)
+		}
+	}
+}
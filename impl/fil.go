@@ -26,6 +26,11 @@ const PublicKeyBytes = 65
 
 type SecretKey = ffi.PrivateKey
 
+// DerivationPath 返回派生钱包地址使用的路径
+func DerivationPath(userId int) string {
+	return fmt.Sprintf("%s%d", filPath, userId)
+}
+
 func CreateSecp256k1FilAddress(mnemonic string, userId int) (string, error) {
 
 	priKey, err := generateSecp256k1PriviteKey(mnemonic, userId)
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/consensus/filcns"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/urfave/cli/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var invokeCmd = &cli.Command{
+	Name:  "invoke",
+	Usage: "调用内置actor（miner、multisig、market、power等）的任意方法，参数以JSON指定",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "from",
+			Usage:    "发送地址",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "接收actor地址",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "method",
+			Usage:    "方法名称或编号，例如 WithdrawBalance 或 16",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "params-json",
+			Usage: "JSON格式的方法参数，字段与actor参数结构一致",
+		},
+		&cli.StringFlag{
+			Name:  "value",
+			Usage: "随消息转账的金额(FIL)",
+			Value: "0",
+		},
+		yesFlag,
+		waitFlag,
+		confidenceFlag,
+	}, msgSendFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		from, err := address.NewFromString(cctx.String("from"))
+		if err != nil {
+			fmt.Printf("解析发送地址失败: %v\n", err)
+			return err
+		}
+
+		to, err := address.NewFromString(cctx.String("to"))
+		if err != nil {
+			fmt.Printf("解析接收地址失败: %v\n", err)
+			return err
+		}
+
+		value, err := types.ParseFIL(cctx.String("value"))
+		if err != nil {
+			fmt.Printf("解析转账金额失败: %v\n", err)
+			return err
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		act, err := api.StateGetActor(ctx, to, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取actor(%s)失败，err:%v\n", to, err)
+			return err
+		}
+
+		methods := filcns.NewActorRegistry().Methods[act.Code]
+		if methods == nil {
+			fmt.Printf("actor(%s)不是内置actor，code: %s\n", to, act.Code)
+			return xerrors.Errorf("unknown actor code %s", act.Code)
+		}
+
+		method, meta, err := lookupMethod(methods, cctx.String("method"))
+		if err != nil {
+			fmt.Printf("actor(%s)没有方法%s，可用的方法: %s\n", to, cctx.String("method"), methodNames(methods))
+			return err
+		}
+
+		params, err := encodeJSONParams(meta, cctx.String("params-json"))
+		if err != nil {
+			fmt.Printf("编码方法参数失败，err:%v\n", err)
+			return err
+		}
+
+		fmt.Printf("调用 %s.%s (method %d)\n", to, meta.Name, method)
+
+		nonce, err := nextNonce(ctx, api, from)
+		if err != nil {
+			fmt.Printf("获取nonce失败，err:%v\n", err)
+			return err
+		}
+
+		c, err := sendMessage(cctx, api, &types.Message{
+			From:   from,
+			To:     to,
+			Value:  abi.TokenAmount(value),
+			Method: method,
+			Params: params,
+			Nonce:  nonce,
+		}, address.Undef)
+		if err != nil {
+			return err
+		}
+		if !c.Defined() {
+			return nil
+		}
+
+		fmt.Println("Message CID:", c)
+
+		if !cctx.Bool("wait") {
+			return nil
+		}
+
+		wait, err := waitMessage(cctx, api, c)
+		if err != nil {
+			return err
+		}
+
+		if ret := decodeJSONReturn(meta, wait.Receipt.Return); ret != "" {
+			fmt.Println("Return:", ret)
+		}
+		return nil
+	},
+}
+
+// 按名称（不区分大小写）或编号查找actor方法
+func lookupMethod(methods map[abi.MethodNum]vm.MethodMeta, name string) (abi.MethodNum, vm.MethodMeta, error) {
+	if n, err := strconv.ParseUint(name, 10, 64); err == nil {
+		meta, ok := methods[abi.MethodNum(n)]
+		if !ok {
+			return 0, vm.MethodMeta{}, xerrors.Errorf("unknown method number %d", n)
+		}
+		return abi.MethodNum(n), meta, nil
+	}
+
+	for num, meta := range methods {
+		if strings.EqualFold(meta.Name, name) {
+			return num, meta, nil
+		}
+	}
+	return 0, vm.MethodMeta{}, xerrors.Errorf("unknown method %s", name)
+}
+
+func methodNames(methods map[abi.MethodNum]vm.MethodMeta) string {
+	var nums []int
+	for num := range methods {
+		nums = append(nums, int(num))
+	}
+	sort.Ints(nums)
+
+	var names []string
+	for _, n := range nums {
+		names = append(names, fmt.Sprintf("%s(%d)", methods[abi.MethodNum(n)].Name, n))
+	}
+	return strings.Join(names, ", ")
+}
+
+// 将JSON参数解析为方法参数类型并编码为CBOR
+func encodeJSONParams(meta vm.MethodMeta, paramsJSON string) ([]byte, error) {
+	p := reflect.New(meta.Params.Elem()).Interface()
+	if _, ok := p.(*abi.EmptyValue); ok {
+		if paramsJSON != "" {
+			return nil, xerrors.Errorf("method %s takes no params", meta.Name)
+		}
+		return nil, nil
+	}
+
+	if paramsJSON == "" {
+		return nil, xerrors.Errorf("method %s requires params, use --params-json", meta.Name)
+	}
+
+	dec := json.NewDecoder(strings.NewReader(paramsJSON))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, xerrors.Errorf("decoding params json for %s: %w", meta.Name, err)
+	}
+
+	m, ok := p.(cbg.CBORMarshaler)
+	if !ok {
+		return nil, xerrors.Errorf("params of %s are not cbor marshalable", meta.Name)
+	}
+	return actors.SerializeParams(m)
+}
+
+// 将方法返回值解码为JSON，无法解码时返回空
+func decodeJSONReturn(meta vm.MethodMeta, ret []byte) string {
+	if len(ret) == 0 || meta.Ret == nil {
+		return ""
+	}
+
+	r, ok := reflect.New(meta.Ret.Elem()).Interface().(cbg.CBORUnmarshaler)
+	if !ok || r.UnmarshalCBOR(bytes.NewReader(ret)) != nil {
+		return ""
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/consensus/filcns"
+	"github.com/filecoin-project/lotus/chain/vm"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"testing"
+)
+
+func TestLookupMethod(t *testing.T) {
+	methods := filcns.NewActorRegistry().Methods[builtin.StorageMinerActorCodeID]
+
+	tests := []struct {
+		name string
+		num  abi.MethodNum
+		ok   bool
+	}{
+		{"WithdrawBalance", miner.Methods.WithdrawBalance, true},
+		{"withdrawbalance", miner.Methods.WithdrawBalance, true},
+		{"16", miner.Methods.WithdrawBalance, true},
+		{"ChangeOwnerAddress", miner.Methods.ChangeOwnerAddress, true},
+		{"23", miner.Methods.ChangeOwnerAddress, true},
+		{"NoSuchMethod", 0, false},
+		{"1000", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tc := range tests {
+		num, meta, err := lookupMethod(methods, tc.name)
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: ok=%v, err=%v", tc.name, tc.ok, err)
+			continue
+		}
+		if tc.ok && (num != tc.num || meta.Params == nil) {
+			t.Errorf("%q: got method %d (%s)", tc.name, num, meta.Name)
+		}
+	}
+}
+
+func TestEncodeJSONParams(t *testing.T) {
+	methods := filcns.NewActorRegistry().Methods[builtin.StorageMinerActorCodeID]
+	withdraw := methods[miner.Methods.WithdrawBalance]
+	confirm := methods[miner.Methods.ConfirmUpdateWorkerKey]
+	owner := methods[miner.Methods.ChangeOwnerAddress]
+
+	var want bytes.Buffer
+	if err := (&miner7.WithdrawBalanceParams{AmountRequested: abi.NewTokenAmount(1000)}).MarshalCBOR(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		meta   vm.MethodMeta
+		params string
+		want   []byte
+		ok     bool
+	}{
+		{withdraw, `{"AmountRequested": "1000"}`, want.Bytes(), true},
+		{withdraw, `{"AmountRequested": "1000", "Extra": 1}`, nil, false},
+		{withdraw, `{"AmountRequested": 1000}`, nil, false},
+		{withdraw, ``, nil, false},
+		// 没有参数的方法
+		{confirm, ``, nil, true},
+		{confirm, `{}`, nil, false},
+		{owner, `"f01000"`, nil, true},
+		{owner, `"not an address"`, nil, false},
+	}
+
+	for _, tc := range tests {
+		got, err := encodeJSONParams(tc.meta, tc.params)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s %q: ok=%v, err=%v", tc.meta.Name, tc.params, tc.ok, err)
+			continue
+		}
+		if tc.want != nil && !bytes.Equal(got, tc.want) {
+			t.Errorf("%s %q: got %x, want %x", tc.meta.Name, tc.params, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/firefly-wallet/impl"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lotus keystore中钱包私钥的名称前缀
+const lotusWalletPrefix = "wallet-"
+
+// lotus keystore文件名为私钥名称的base32编码（无padding）
+var lotusKeyNameEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type lotusKeystoreEntry struct {
+	Key    *impl.Key
+	Status string
+}
+
+// 从lotus keystore目录批量导入钱包私钥
+func importLotusKeystore(cctx *cli.Context, dir string) error {
+	entries, err := readLotusKeystore(dir)
+	if err != nil {
+		fmt.Printf("读取lotus keystore目录(%s)失败，err: %v\n", dir, err)
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("keystore中没有找到钱包私钥")
+		return nil
+	}
+
+	tw := tablewriter.New(
+		tablewriter.Col("No"),
+		tablewriter.Col("Address"),
+		tablewriter.Col("Type"),
+		tablewriter.Col("Status"))
+
+	var candidates []int
+	for i, e := range entries {
+		if _, err := localdb.Get(db.KeyAddr, e.Key.Address.String()); err == nil {
+			e.Status = "已存在，跳过"
+		} else {
+			e.Status = "新地址"
+			candidates = append(candidates, i)
+		}
+
+		tw.Write(map[string]interface{}{
+			"No":      i + 1,
+			"Address": e.Key.Address,
+			"Type":    e.Key.Type,
+			"Status":  e.Status,
+		})
+	}
+	if err := tw.Flush(os.Stdout); err != nil {
+		return err
+	}
+
+	if len(candidates) == 0 {
+		fmt.Println("所有地址都已存在，无需导入")
+		return nil
+	}
+
+	selected := candidates
+	if !cctx.Bool("all") {
+		reader := bufio.NewReader(os.Stdin)
+		fmt.Print("请输入要导入的编号(逗号分隔，输入all导入全部新地址): ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		selected, err = parseKeystoreSelection(strings.TrimSpace(line), candidates, len(entries))
+		if err != nil {
+			fmt.Println("输入的编号不正确,", err)
+			return err
+		}
+	}
+
+	for _, i := range selected {
+		if err := saveImportedKey(entries[i].Key); err != nil {
+			return err
+		}
+		fmt.Println("成功导入钱包：", entries[i].Key.Address.String())
+	}
+
+	return nil
+}
+
+// 读取lotus keystore中的钱包私钥，同一地址只保留一个
+func readLotusKeystore(dir string) ([]*lotusKeystoreEntry, error) {
+	// 允许直接指定lotus repo目录
+	if _, err := os.Stat(filepath.Join(dir, "keystore")); err == nil {
+		dir = filepath.Join(dir, "keystore")
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	var entries []*lotusKeystoreEntry
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		name, err := lotusKeyNameEncoding.DecodeString(f.Name())
+		if err != nil || !strings.HasPrefix(string(name), lotusWalletPrefix) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var ki types.KeyInfo
+		if err := json.Unmarshal(data, &ki); err != nil {
+			return nil, xerrors.Errorf("decoding key %s: %w", name, err)
+		}
+
+		key, err := impl.NewKey(&ki)
+		if err != nil {
+			return nil, xerrors.Errorf("loading key %s: %w", name, err)
+		}
+
+		if _, ok := seen[key.Address.String()]; ok {
+			continue
+		}
+		seen[key.Address.String()] = struct{}{}
+
+		entries = append(entries, &lotusKeystoreEntry{Key: key})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key.Address.String() < entries[j].Key.Address.String()
+	})
+	return entries, nil
+}
+
+func parseKeystoreSelection(input string, candidates []int, total int) ([]int, error) {
+	if input == "all" {
+		return candidates, nil
+	}
+
+	allowed := map[int]struct{}{}
+	for _, i := range candidates {
+		allowed[i] = struct{}{}
+	}
+
+	var selected []int
+	for _, s := range strings.Split(input, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > total {
+			return nil, xerrors.Errorf("invalid number %q", s)
+		}
+		if _, ok := allowed[n-1]; !ok {
+			return nil, xerrors.Errorf("address %d already exists", n)
+		}
+		selected = append(selected, n-1)
+	}
+
+	return selected, nil
+}
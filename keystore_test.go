@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeystoreSelection(t *testing.T) {
+	// 共5个地址，第2个(下标1)已经存在
+	candidates := []int{0, 2, 3, 4}
+
+	tests := []struct {
+		input string
+		want  []int
+		ok    bool
+	}{
+		{"all", []int{0, 2, 3, 4}, true},
+		{"1", []int{0}, true},
+		{"1,3, 5", []int{0, 2, 4}, true},
+		{" 4 ,", []int{3}, true},
+		{"", nil, true},
+		{"2", nil, false},
+		{"0", nil, false},
+		{"6", nil, false},
+		{"-1", nil, false},
+		{"1,x", nil, false},
+		{"ALL", nil, false},
+	}
+
+	for _, tc := range tests {
+		got, err := parseKeystoreSelection(tc.input, candidates, 5)
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: ok=%v, err=%v", tc.input, tc.ok, err)
+			continue
+		}
+		if tc.ok && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
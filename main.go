@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	crypto2 "github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/blake2b"
 	"github.com/filecoin-project/firefly-wallet/db"
@@ -52,6 +53,30 @@ type FilAddressInfo struct {
 	AddrType string
 	Index    int
 	Address  string
+	Label    string   `json:",omitempty"`
+	Roles    []string `json:",omitempty"`
+	Archived bool     `json:",omitempty"`
+}
+
+func getAddressInfo(addr string) (*FilAddressInfo, error) {
+	faiByte, err := localdb.Get(db.KeyAddr, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	fai := &FilAddressInfo{}
+	if err := json.Unmarshal(faiByte, fai); err != nil {
+		return nil, err
+	}
+	return fai, nil
+}
+
+func putAddressInfo(fai *FilAddressInfo) error {
+	faiByte, err := json.Marshal(fai)
+	if err != nil {
+		return err
+	}
+	return localdb.Add(db.KeyAddr, fai.Address, faiByte)
 }
 
 func getRepoPath() string {
@@ -114,6 +139,7 @@ func signMessage(msg []byte, addr address.Address) (*crypto.Signature, error) {
 		sb, err := unRecoverAddrSign(msg, addr)
 		if err != nil {
 			fmt.Printf("签名失败,err: %v\n", err)
+			return &crypto.Signature{}, err
 		}
 
 		return sb, nil
@@ -122,6 +148,7 @@ func signMessage(msg []byte, addr address.Address) (*crypto.Signature, error) {
 		sb, err := impl.Sign(msg, addr, string(localMnenoic), fai.Index)
 		if err != nil {
 			fmt.Printf("签名失败,err: %v\n", err)
+			return &crypto.Signature{}, err
 		}
 
 		return sb, nil
@@ -229,10 +256,30 @@ func main() {
 		listCmd,
 		importAddressCmd,
 		signCmd,
+		recipientKeygenCmd,
+		signMessageFileCmd,
+		pushCmd,
+		buildMessageCmd,
+		configCmd,
+		sendBatchCmd,
+		nonceCmd,
+		replaceCmd,
+		cancelCmd,
+		invokeCmd,
+		sweepCmd,
+		statusCmd,
+		historyCmd,
+		policyCmd,
+		msigCmd,
+		marketCmd,
+		archiveCmd,
+		deleteCmd,
 		setOwnerCmd,
 		proposeChangeWorker,
-		//controlListCmd,
-		//controlSetCmd,
+		confirmChangeWorker,
+		controlListCmd,
+		controlSetCmd,
+		minerInfoCmd,
 	}
 
 	app := &cli.App{
@@ -244,6 +291,7 @@ func main() {
 				Name:  "db-dir",
 				Value: "./data",
 			},
+			dryRunFlag,
 		},
 		Commands: local,
 	}
@@ -261,6 +309,18 @@ var exportAddressCmd = &cli.Command{
 			Name:  "address",
 			Usage: "导出地址",
 		},
+		&cli.BoolFlag{
+			Name:  "encrypt",
+			Usage: "将私钥加密后写入文件，不输出明文私钥",
+		},
+		&cli.StringSliceFlag{
+			Name:  "recipient",
+			Usage: "接收方公钥(age1...)，可指定多个；不指定时使用一次性口令加密",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "加密文件保存路径，默认为 <address>.ffkey",
+		},
 	},
 	Before: func(context *cli.Context) error {
 		if err := _init(); err != nil {
@@ -279,77 +339,90 @@ var exportAddressCmd = &cli.Command{
 			return nil
 		}
 
-		faiByte, err := localdb.Get(db.KeyAddr, address)
+		privKey, err := exportPrivateKey(address)
 		if err != nil {
-			fmt.Printf("从数据库读取钱包失败！,err: %v", err)
-			return nil
+			return err
 		}
 
-		fai := FilAddressInfo{}
-		err = json.Unmarshal(faiByte, &fai)
-		if err != nil {
-			fmt.Printf("反序列化数据(%v)失败！,err: %v", faiByte, err)
-			return nil
+		if cctx.Bool("encrypt") {
+			return writeKeyBundle(cctx, address, privKey)
 		}
 
-		var privKey string
-		if fai.Index == unRecoverIndex {
-			encryptKey, err := localdb.Get(db.KeyPriKey, address)
-			if err != nil {
-				fmt.Printf("从数据库读取钱包失败！,err: %v", err)
-				return nil
-			}
+		fmt.Println(privKey)
+		return nil
+	},
+}
 
-			inpdata, err := mnemonic.Decrypt(encryptKey, passwd)
-			if err != nil {
-				fmt.Printf("读取私钥出错,err: %v", err)
-				return nil
-			}
+// 导出钱包私钥，格式为hex-lotus
+func exportPrivateKey(address string) (string, error) {
+	faiByte, err := localdb.Get(db.KeyAddr, address)
+	if err != nil {
+		fmt.Printf("从数据库读取钱包失败！,err: %v\n", err)
+		return "", err
+	}
 
-			var ki types.KeyInfo
-			data, err := hex.DecodeString(strings.TrimSpace(string(inpdata)))
-			if err != nil {
-				fmt.Println("输入的私钥格式不正确，解析出错！")
-				return err
-			}
+	fai := FilAddressInfo{}
+	err = json.Unmarshal(faiByte, &fai)
+	if err != nil {
+		fmt.Printf("反序列化数据(%v)失败！,err: %v\n", faiByte, err)
+		return "", err
+	}
 
-			if err := json.Unmarshal(data, &ki); err != nil {
-				fmt.Println("输入的私钥格式不正确，序列化出错！")
-				return err
-			}
+	if fai.Index == unRecoverIndex {
+		encryptKey, err := localdb.Get(db.KeyPriKey, address)
+		if err != nil {
+			fmt.Printf("从数据库读取钱包失败！,err: %v\n", err)
+			return "", err
+		}
 
-			b, err := json.Marshal(ki)
-			if err != nil {
-				fmt.Println("序列化私钥出错，原因:", err.Error())
-				return err
-			}
+		inpdata, err := mnemonic.Decrypt(encryptKey, passwd)
+		if err != nil {
+			fmt.Printf("读取私钥出错,err: %v\n", err)
+			return "", err
+		}
 
-			privKey = hex.EncodeToString(b)
+		var ki types.KeyInfo
+		data, err := hex.DecodeString(strings.TrimSpace(string(inpdata)))
+		if err != nil {
+			fmt.Println("输入的私钥格式不正确，解析出错！")
+			return "", err
+		}
 
-		} else {
-			if strings.HasPrefix(fai.Address, "f3") || strings.HasPrefix(fai.Address, "t3") {
-				privKey, err = impl.ExportBlsAddress(string(localMnenoic), fai.Index)
-				if err != nil {
-					fmt.Printf("导出BLS钱包失败！,err: %v", err)
-					return nil
-				}
-			} else {
-				privKey, err = impl.ExportSecp256k1Address(string(localMnenoic), fai.Index)
-				if err != nil {
-					fmt.Printf("导出Secp256钱包失败！,err: %v", err)
-					return nil
-				}
-			}
+		if err := json.Unmarshal(data, &ki); err != nil {
+			fmt.Println("输入的私钥格式不正确，序列化出错！")
+			return "", err
 		}
 
-		fmt.Println(privKey)
-		return nil
-	},
+		b, err := json.Marshal(ki)
+		if err != nil {
+			fmt.Println("序列化私钥出错，原因:", err.Error())
+			return "", err
+		}
+
+		return hex.EncodeToString(b), nil
+	}
+
+	if strings.HasPrefix(fai.Address, "f3") || strings.HasPrefix(fai.Address, "t3") {
+		privKey, err := impl.ExportBlsAddress(string(localMnenoic), fai.Index)
+		if err != nil {
+			fmt.Printf("导出BLS钱包失败！,err: %v\n", err)
+			return "", err
+		}
+		return privKey, nil
+	}
+
+	privKey, err := impl.ExportSecp256k1Address(string(localMnenoic), fai.Index)
+	if err != nil {
+		fmt.Printf("导出Secp256钱包失败！,err: %v\n", err)
+		return "", err
+	}
+	return privKey, nil
 }
+
 var sendCmd = &cli.Command{
 	Name:  "send",
 	Usage: "转账",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.StringFlag{
 			Name:  "from",
 			Usage: "转账源账户",
@@ -362,22 +435,24 @@ var sendCmd = &cli.Command{
 			Name:  "amount",
 			Usage: "转账金额",
 		},
-	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "转出全部余额，金额为余额减去最大手续费(GasFeeCap*GasLimit)，不能与--amount同时使用",
+		},
+		yesFlag,
+		waitFlag,
+		confidenceFlag,
+	}, msgSendFlags...),
+	Before: initForSend,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
 
-		if cctx.String("from") == "" || cctx.String("to") == "" || cctx.String("amount") == "" {
+		if cctx.String("from") == "" || cctx.String("to") == "" || (cctx.String("amount") == "" && !cctx.Bool("all")) {
 
-			fmt.Println("必须指定--from，--to，--amount.")
+			fmt.Println("必须指定--from，--to，--amount（或--all）.")
 			return nil
 		}
 
@@ -403,13 +478,20 @@ var sendCmd = &cli.Command{
 			return fmt.Errorf("failed to parse target address: %w\n", err)
 		}
 
-		amount, err := types.ParseFIL(cctx.String("amount"))
-		if err != nil {
+		if cctx.Bool("all") {
+			if cctx.IsSet("amount") {
+				fmt.Println("--all 不能与 --amount 同时使用")
+				return xerrors.New("--all and --amount are mutually exclusive")
+			}
+		} else {
+			amount, err := types.ParseFIL(cctx.String("amount"))
+			if err != nil {
 
-			fmt.Printf("解析转账金额地址失败: %v\n", err)
-			return fmt.Errorf("解析转账金额失败: %v", err)
+				fmt.Printf("解析转账金额地址失败: %v\n", err)
+				return fmt.Errorf("解析转账金额失败: %v", err)
+			}
+			msg.Value = abi.TokenAmount(amount)
 		}
-		msg.Value = abi.TokenAmount(amount)
 
 		if strings.Compare(msg.From.String(), msg.To.String()) == 0 {
 
@@ -420,45 +502,33 @@ var sendCmd = &cli.Command{
 		msg.Method = builtin.MethodSend
 
 		// 获取nonce
-		msg.Nonce, err = api.MpoolGetNonce(ctx, msg.From)
+		msg.Nonce, err = nextNonce(ctx, api, msg.From)
 		if err != nil {
-			fmt.Printf("读取获取消息池中的的nonce失败，err:%v\n", err)
-			a, err := api.StateGetActor(ctx, msg.From, types.EmptyTSK)
-			if err != nil {
-				fmt.Printf("读取获取链数据上的的nonce失败，err:%v\n", err)
+			fmt.Printf("获取nonce失败，err:%v\n", err)
+			return err
+		}
+
+		if cctx.Bool("all") {
+			if err := prepareSweep(cctx, api, msg); err != nil {
 				return err
 			}
-			msg.Nonce = a.Nonce
+			fmt.Printf("转出全部余额: %s\n", types.FIL(msg.Value))
 		}
 
-		msg, err = api.GasEstimateMessageGas(ctx, msg, nil, types.EmptyTSK)
+		cid, err := sendMessage(cctx, api, msg, address.Undef)
 		if err != nil {
-			fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
-			return xerrors.Errorf("GasEstimateMessageGas error: %w", err)
+			return err
 		}
-
-		mb, err := msg.ToStorageBlock()
-		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v", err)
-			return xerrors.Errorf("serializing message: %w", err)
+		if !cid.Defined() {
+			return nil
 		}
 
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
+		fmt.Printf("Sent transfer in message %s\n", cid.String())
 
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
+		if cctx.Bool("wait") {
+			_, err := waitMessage(cctx, api, cid)
 			return err
 		}
-
-		fmt.Printf("Requested rewards withdrawal in message %s\n", cid.String())
-
 		return nil
 	},
 }
@@ -541,20 +611,42 @@ var initCmd = &cli.Command{
 		}
 
 		// 初始化创建一个钱包地址,用于后续验证密码使用
-		createAddress(false, false)
-		return nil
+		return createAddress(false, false)
 	},
 }
 
 var newAddressCmd = &cli.Command{
 	Name:  "new-address",
-	Usage: "创建一个新的钱包地址",
+	Usage: "创建新的钱包地址，可以通过--count批量创建",
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
 			Name:  "bls",
 			Usage: "创建bls类型的钱包地址",
 			Value: false,
 		},
+		&cli.BoolFlag{
+			Name:  "secp",
+			Usage: "创建secp256k1类型的钱包地址（默认）",
+			Value: false,
+		},
+		&cli.IntFlag{
+			Name:  "count",
+			Usage: "创建钱包地址的数量",
+			Value: 1,
+		},
+		&cli.StringFlag{
+			Name:  "label",
+			Usage: "地址标签，批量创建时作为前缀，标签为 <label>-<index>",
+		},
+		&cli.StringSliceFlag{
+			Name:  "role",
+			Usage: "地址用途标记，例如 deposit, owner, worker, control，可指定多个",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "输出格式: text, table, json",
+			Value: "text",
+		},
 		&cli.BoolFlag{
 			Name:   "show-private-key",
 			Usage:  "显示私钥",
@@ -574,12 +666,86 @@ var newAddressCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
-		showPK := context.Bool("show-private-key")
-		createAddress(showPK, context.Bool("bls"))
+		if context.Bool("bls") && context.Bool("secp") {
+			fmt.Println("--bls 和 --secp 不能同时指定")
+			return fmt.Errorf("--bls and --secp are mutually exclusive")
+		}
+
+		count := context.Int("count")
+		if count < 1 {
+			fmt.Println("--count 必须大于0")
+			return fmt.Errorf("invalid count: %d", count)
+		}
+
+		fais, err := generateAddresses(count, context.Bool("bls"), context.String("label"), context.StringSlice("role"))
+		if err != nil {
+			return err
+		}
+
+		if err := printAddresses(fais, context.String("output")); err != nil {
+			return err
+		}
+
+		if context.Bool("show-private-key") {
+			return printPrivateKeys(fais)
+		}
 		return nil
 	},
 }
 
+func printAddresses(fais []FilAddressInfo, format string) error {
+	switch format {
+	case "text":
+		for _, fai := range fais {
+			fmt.Println(fai.Address)
+		}
+	case "table":
+		tw := tablewriter.New(
+			tablewriter.Col("Address"),
+			tablewriter.Col("Index"),
+			tablewriter.Col("Path"),
+			tablewriter.Col("Label"),
+			tablewriter.Col("Roles"))
+		for _, fai := range fais {
+			tw.Write(map[string]interface{}{
+				"Address": fai.Address,
+				"Index":   fai.Index,
+				"Path":    impl.DerivationPath(fai.Index),
+				"Label":   fai.Label,
+				"Roles":   strings.Join(fai.Roles, ","),
+			})
+		}
+		return tw.Flush(os.Stdout)
+	case "json":
+		type jsonAddress struct {
+			Address string
+			Index   int
+			Path    string
+			Label   string   `json:",omitempty"`
+			Roles   []string `json:",omitempty"`
+		}
+		out := make([]jsonAddress, 0, len(fais))
+		for _, fai := range fais {
+			out = append(out, jsonAddress{
+				Address: fai.Address,
+				Index:   fai.Index,
+				Path:    impl.DerivationPath(fai.Index),
+				Label:   fai.Label,
+				Roles:   fai.Roles,
+			})
+		}
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	default:
+		fmt.Println("不支持的输出格式:", format)
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+	return nil
+}
+
 var importAddressCmd = &cli.Command{
 	Name:      "import",
 	Usage:     "导入钱包地址，注意：导入的钱包地址,因不是助记词派生的地址，无法通过助记词找回来。",
@@ -587,9 +753,25 @@ var importAddressCmd = &cli.Command{
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:  "format",
-			Usage: "specify input format for key",
+			Usage: "specify input format for key: hex-lotus, json-lotus, gfc-json, eth-keystore, raw-secp256k1",
 			Value: "hex-lotus",
 		},
+		&cli.BoolFlag{
+			Name:  "encrypted",
+			Usage: "导入export-address --encrypt导出的加密文件",
+		},
+		&cli.StringSliceFlag{
+			Name:  "identity",
+			Usage: "解密用的私钥文件(AGE-SECRET-KEY-1...)，不指定时提示输入一次性口令",
+		},
+		&cli.StringFlag{
+			Name:  "lotus-keystore",
+			Usage: "从lotus repo的keystore目录批量导入钱包",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "配合--lotus-keystore使用，导入所有新地址，不再逐个选择",
+		},
 	},
 	Before: func(context *cli.Context) error {
 		if err := _init(); err != nil {
@@ -603,6 +785,15 @@ var importAddressCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
+		if dir := cctx.String("lotus-keystore"); dir != "" {
+			return importLotusKeystore(cctx, dir)
+		}
+
+		if cctx.Bool("encrypted") && (!cctx.Args().Present() || cctx.Args().First() == "-") {
+			fmt.Println("导入加密文件必须指定文件路径")
+			return fmt.Errorf("must pass bundle path with --encrypted")
+		}
+
 		var inpdata []byte
 		if !cctx.Args().Present() || cctx.Args().First() == "-" {
 			reader := bufio.NewReader(os.Stdin)
@@ -621,8 +812,18 @@ var importAddressCmd = &cli.Command{
 			inpdata = fdata
 		}
 
+		format := cctx.String("format")
+		var bundleAddr string
+		if cctx.Bool("encrypted") {
+			b, data, err := openKeyBundle(cctx, inpdata)
+			if err != nil {
+				return err
+			}
+			inpdata, format, bundleAddr = data, b.Format, b.Address
+		}
+
 		var ki types.KeyInfo
-		switch cctx.String("format") {
+		switch format {
 		case "hex-lotus":
 			data, err := hex.DecodeString(strings.TrimSpace(string(inpdata)))
 			if err != nil {
@@ -662,9 +863,39 @@ var importAddressCmd = &cli.Command{
 				fmt.Println("解析私钥 信息失败，无法识别的私钥类型!!")
 				return fmt.Errorf("unrecognized key type: %d", gk.SigType)
 			}
+		case "eth-keystore":
+			fmt.Print("请输入keystore密码:")
+			ksPasswd, err := gopass.GetPasswdMasked()
+			if err != nil {
+				fmt.Printf("输入密码异常，%v\n", err)
+				return err
+			}
+
+			ek, err := keystore.DecryptKey(inpdata, string(ksPasswd))
+			if err != nil {
+				fmt.Println("解密keystore失败，密码错误或文件格式不正确！")
+				return xerrors.Errorf("failed to decrypt ethereum keystore: %w", err)
+			}
+
+			ki.Type = types.KTSecp256k1
+			ki.PrivateKey = crypto2.FromECDSA(ek.PrivateKey)
+		case "raw-secp256k1":
+			data, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(inpdata)), "0x"))
+			if err != nil {
+				fmt.Println("输入的私钥格式不正确，解析出错！")
+				return err
+			}
+
+			if len(data) != impl.PrivateKeyBytes {
+				fmt.Printf("输入的私钥长度不正确，需要%d字节，实际%d字节\n", impl.PrivateKeyBytes, len(data))
+				return fmt.Errorf("invalid secp256k1 key length: %d", len(data))
+			}
+
+			ki.Type = types.KTSecp256k1
+			ki.PrivateKey = data
 		default:
 			fmt.Println("解析私钥 信息失败，不识别的格式!!")
-			return fmt.Errorf("unrecognized format: %s", cctx.String("format"))
+			return fmt.Errorf("unrecognized format: %s", format)
 		}
 
 		//api, closer, err := lcli.GetFullNodeAPI(cctx)
@@ -682,29 +913,12 @@ var importAddressCmd = &cli.Command{
 			return err
 		}
 
-		filInfo := FilAddressInfo{Address: key.Address.String(), Index: unRecoverIndex, AddrType: string(key.Type)}
-		filInfoByte, err := json.Marshal(&filInfo)
-		if err != nil {
-			fmt.Println("序列化filInfo失败!")
-			return err
+		if bundleAddr != "" && bundleAddr != key.Address.String() {
+			fmt.Printf("加密文件中的地址(%s)与私钥地址(%s)不一致\n", bundleAddr, key.Address)
+			return fmt.Errorf("bundle address %s does not match key address %s", bundleAddr, key.Address)
 		}
 
-		err = localdb.Add(db.KeyAddr, key.Address.String(), filInfoByte)
-		if err != nil {
-			fmt.Println("保存钱包地址到数据异常，原因：", err.Error())
-			return err
-		}
-
-		// 保存privateKey,到数据库
-		encryData, err := mnemonic.EncryptData(inpdata, passwd)
-		if err != nil {
-			fmt.Println("加密私钥失败！原因：", err.Error())
-			return err
-		}
-
-		err = localdb.Add(db.KeyPriKey, key.Address.String(), encryData)
-		if err != nil {
-			fmt.Println("保存钱包地址到数据异常，原因：", err.Error())
+		if err := saveImportedKey(key); err != nil {
 			return err
 		}
 
@@ -713,6 +927,43 @@ var importAddressCmd = &cli.Command{
 	},
 }
 
+// 保存导入的钱包地址及加密后的私钥
+func saveImportedKey(key *impl.Key) error {
+	filInfo := FilAddressInfo{Address: key.Address.String(), Index: unRecoverIndex, AddrType: string(key.Type)}
+	filInfoByte, err := json.Marshal(&filInfo)
+	if err != nil {
+		fmt.Println("序列化filInfo失败!")
+		return err
+	}
+
+	err = localdb.Add(db.KeyAddr, key.Address.String(), filInfoByte)
+	if err != nil {
+		fmt.Println("保存钱包地址到数据异常，原因：", err.Error())
+		return err
+	}
+
+	// 保存privateKey,到数据库，统一保存为hex-lotus格式
+	kib, err := json.Marshal(key.KeyInfo)
+	if err != nil {
+		fmt.Println("序列化私钥出错，原因:", err.Error())
+		return err
+	}
+
+	encryData, err := mnemonic.EncryptData([]byte(hex.EncodeToString(kib)), passwd)
+	if err != nil {
+		fmt.Println("加密私钥失败！原因：", err.Error())
+		return err
+	}
+
+	err = localdb.Add(db.KeyPriKey, key.Address.String(), encryData)
+	if err != nil {
+		fmt.Println("保存钱包地址到数据异常，原因：", err.Error())
+		return err
+	}
+
+	return nil
+}
+
 var listCmd = &cli.Command{
 	Name:  "list",
 	Usage: "展示钱包列表",
@@ -732,6 +983,10 @@ var listCmd = &cli.Command{
 			Usage:   "展示market余额",
 			Aliases: []string{"m"},
 		},
+		&cli.BoolFlag{
+			Name:  "archived",
+			Usage: "同时展示已归档的钱包地址",
+		},
 	},
 	Before: func(context *cli.Context) error {
 		if err := _init(); err != nil {
@@ -764,12 +1019,15 @@ var listCmd = &cli.Command{
 
 		tw := tablewriter.New(
 			tablewriter.Col("Address"),
+			tablewriter.Col("Label"),
+			tablewriter.Col("Roles"),
 			tablewriter.Col("ID"),
 			tablewriter.Col("Balance"),
 			tablewriter.Col("Market(Avail)"),
 			tablewriter.Col("Market(Locked)"),
 			tablewriter.Col("Nonce"),
 			tablewriter.Col("Default"),
+			tablewriter.Col("Archived"),
 			tablewriter.NewLineCol("Error"))
 
 		for _, a := range addrs {
@@ -780,6 +1038,10 @@ var listCmd = &cli.Command{
 				return err
 			}
 
+			if fa.Archived && !cctx.Bool("archived") {
+				continue
+			}
+
 			if cctx.Bool("addr-only") {
 				fmt.Println(addr)
 			} else {
@@ -803,9 +1065,18 @@ var listCmd = &cli.Command{
 					"Balance": types.FIL(a.Balance),
 					"Nonce":   a.Nonce,
 				}
+				if fa.Label != "" {
+					row["Label"] = fa.Label
+				}
+				if len(fa.Roles) > 0 {
+					row["Roles"] = strings.Join(fa.Roles, ",")
+				}
 				if addr == def {
 					row["Default"] = "X"
 				}
+				if fa.Archived {
+					row["Archived"] = "X"
+				}
 
 				if cctx.Bool("id") {
 					id, err := api.StateLookupID(ctx, addr, types.EmptyTSK)
@@ -875,6 +1146,10 @@ var signCmd = &cli.Command{
 			return err
 		}
 
+		if err := enforceRawPolicy(); err != nil {
+			return err
+		}
+
 		sig, err := signMessage(msg, addr)
 		if err != nil {
 			return err
@@ -887,14 +1162,17 @@ var signCmd = &cli.Command{
 	},
 }
 
-func createAddress(show, bls bool) {
-	if bls {
-		// t3...
-		generateBlsFilAddress(show)
-	} else {
-		// t1....
-		generateFilAddress(show)
+func createAddress(show, bls bool) error {
+	fais, err := generateAddresses(1, bls, "", nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(fais[0].Address)
+	if show {
+		return printPrivateKeys(fais)
 	}
+	return nil
 }
 
 func getNextIndex() int {
@@ -915,82 +1193,74 @@ func getNextIndex() int {
 	return index
 }
 
-func generateBlsFilAddress(showPK bool) {
-	index := getNextIndex()
-	filAddr, err := impl.CreateBlsFilAddress(string(localMnenoic), index)
-	if err != nil {
-		panic(err)
-	}
-
-	fai := FilAddressInfo{
-		Address: filAddr,
-		Index:   index,
-	}
+// 批量派生钱包地址，所有地址记录和下一个index在同一个batch中写入
+func generateAddresses(count int, bls bool, label string, roles []string) ([]FilAddressInfo, error) {
+	mnenoic := string(localMnenoic)
+	start := getNextIndex()
 
-	//fmt.Println(fai)
+	batch := localdb.NewBatch()
+	fais := make([]FilAddressInfo, 0, count)
+	for index := start; index < start+count; index++ {
+		fai := FilAddressInfo{
+			Index: index,
+			Roles: roles,
+		}
 
-	if showPK {
-		priKey, err := impl.ExportBlsAddress(string(localMnenoic), index)
+		var err error
+		if bls {
+			// t3...
+			fai.AddrType = string(types.KTBLS)
+			fai.Address, err = impl.CreateBlsFilAddress(mnenoic, index)
+		} else {
+			// t1....
+			fai.AddrType = string(types.KTSecp256k1)
+			fai.Address, err = impl.CreateSecp256k1FilAddress(mnenoic, index)
+		}
 		if err != nil {
-			panic(err)
+			fmt.Printf("派生钱包地址失败，index: %d, err: %v\n", index, err)
+			return nil, err
 		}
-		fmt.Println(priKey)
-	}
-
-	faiByte, err := json.Marshal(&fai)
-	fmt.Println(filAddr)
-	if err != nil {
-		panic(err)
-	}
 
-	err = localdb.Add(db.KeyAddr, filAddr, faiByte)
-	if err != nil {
-		panic(err)
-	}
+		if label != "" {
+			fai.Label = label
+			if count > 1 {
+				fai.Label = fmt.Sprintf("%s-%d", label, index)
+			}
+		}
 
-	err = localdb.Add(db.KeyIndex, NEXT, []byte(fmt.Sprintf("%d", index+1)))
-	if err != nil {
-		panic(err)
-	}
-}
+		faiByte, err := json.Marshal(&fai)
+		if err != nil {
+			return nil, err
+		}
 
-func generateFilAddress(showPK bool) {
-	mnenoic := string(localMnenoic)
-	index := getNextIndex()
-	filAddr, err := impl.CreateSecp256k1FilAddress(mnenoic, index)
-	if err != nil {
-		panic(err)
+		batch.Add(db.KeyAddr, fai.Address, faiByte)
+		fais = append(fais, fai)
 	}
 
-	fai := FilAddressInfo{
-		Address: filAddr,
-		Index:   index,
+	batch.Add(db.KeyIndex, NEXT, []byte(fmt.Sprintf("%d", start+count)))
+	if err := batch.Commit(); err != nil {
+		fmt.Printf("保存钱包地址到数据库失败，err: %v\n", err)
+		return nil, err
 	}
 
-	fmt.Println(filAddr)
+	return fais, nil
+}
 
-	if showPK {
-		priKey, err := impl.ExportSecp256k1Address(mnenoic, index)
+func printPrivateKeys(fais []FilAddressInfo) error {
+	for _, fai := range fais {
+		var priKey string
+		var err error
+		if fai.AddrType == string(types.KTBLS) {
+			priKey, err = impl.ExportBlsAddress(string(localMnenoic), fai.Index)
+		} else {
+			priKey, err = impl.ExportSecp256k1Address(string(localMnenoic), fai.Index)
+		}
 		if err != nil {
-			panic(err)
+			return err
 		}
 		fmt.Println(priKey)
 	}
-
-	faiByte, err := json.Marshal(&fai)
-	if err != nil {
-		panic(err)
-	}
-
-	err = localdb.Add(db.KeyAddr, filAddr, faiByte)
-	if err != nil {
-		panic(err)
-	}
-
-	err = localdb.Add(db.KeyIndex, NEXT, []byte(fmt.Sprintf("%d", index+1)))
-	if err != nil {
-		panic(err)
-	}
+	return nil
 }
 
 func getPassword() ([]byte, error) {
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"strings"
+)
+
+var archiveCmd = &cli.Command{
+	Name:      "archive",
+	Usage:     "归档钱包地址，归档后list默认不再展示，但仍然可以签名",
+	ArgsUsage: "[address...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "restore",
+			Usage: "取消归档",
+		},
+	},
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		if !cctx.Args().Present() {
+			fmt.Println("必须指定钱包地址")
+			return fmt.Errorf("must pass address")
+		}
+
+		for _, a := range cctx.Args().Slice() {
+			fai, err := getAddressInfo(a)
+			if err != nil {
+				fmt.Printf("从数据库读取钱包(%s)失败！,err: %v\n", a, err)
+				return err
+			}
+
+			fai.Archived = !cctx.Bool("restore")
+			if err := putAddressInfo(fai); err != nil {
+				fmt.Printf("保存钱包(%s)失败！,err: %v\n", a, err)
+				return err
+			}
+
+			if fai.Archived {
+				fmt.Println("已归档:", a)
+			} else {
+				fmt.Println("已取消归档:", a)
+			}
+		}
+		return nil
+	},
+}
+
+var deleteCmd = &cli.Command{
+	Name:      "delete",
+	Usage:     "删除余额为0且不再使用的钱包地址",
+	ArgsUsage: "[address]",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "miner",
+			Usage: "需要检查的矿工编号，可指定多个",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "地址是矿工的owner/worker/control地址时仍然删除，不能跳过余额检查。请谨慎操作",
+		},
+	},
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定一个钱包地址")
+			return fmt.Errorf("must pass one address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			fmt.Println("解析钱包地址失败,", err)
+			return err
+		}
+
+		fai, err := getAddressInfo(addr.String())
+		if err != nil {
+			fmt.Printf("从数据库读取钱包(%s)失败！,err: %v\n", addr, err)
+			return err
+		}
+
+		miners := cctx.StringSlice("miner")
+		if fai.MinerId != "" {
+			miners = append(miners, fai.MinerId)
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		// 有余额的地址删除后资金无法再转出，--force也不能跳过
+		if err := checkAddressEmpty(ctx, api, addr); err != nil {
+			fmt.Printf("钱包地址(%s)仍有余额，不能删除: %v\n", addr, err)
+			return err
+		}
+
+		if err := checkAddressRoles(ctx, api, addr, miners); err != nil {
+			if !cctx.Bool("force") {
+				fmt.Printf("钱包地址(%s)仍在使用中，不能删除: %v\n", addr, err)
+				return err
+			}
+			fmt.Printf("钱包地址(%s)仍在使用中: %v，已指定--force，继续删除\n", addr, err)
+		}
+
+		if len(miners) == 0 {
+			fmt.Println("未指定矿工，没有检查矿工角色，可以通过--miner指定")
+		}
+
+		batch := localdb.NewBatch()
+		batch.Del(db.KeyAddr, addr.String())
+		if fai.Index == unRecoverIndex {
+			batch.Del(db.KeyPriKey, addr.String())
+		}
+		if err := batch.Commit(); err != nil {
+			fmt.Printf("删除钱包(%s)失败！,err: %v\n", addr, err)
+			return err
+		}
+
+		fmt.Println("已删除:", addr)
+		return nil
+	},
+}
+
+// 检查钱包地址链上余额为0
+func checkAddressEmpty(ctx context.Context, api v0api.FullNode, addr address.Address) error {
+	act, err := api.StateGetActor(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		if strings.Contains(err.Error(), "actor not found") {
+			// 链上不存在的地址不会有余额
+			return nil
+		}
+		return err
+	}
+
+	if !act.Balance.IsZero() {
+		return xerrors.Errorf("balance is %s", types.FIL(act.Balance))
+	}
+	return nil
+}
+
+// 检查钱包地址不是指定矿工的owner/worker/control地址
+func checkAddressRoles(ctx context.Context, api v0api.FullNode, addr address.Address, miners []string) error {
+	if len(miners) == 0 {
+		return nil
+	}
+
+	id, err := api.StateLookupID(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		if strings.Contains(err.Error(), "actor not found") {
+			// 链上不存在的地址不可能是矿工的地址
+			return nil
+		}
+		return err
+	}
+
+	for _, m := range miners {
+		maddr, err := address.NewFromString(m)
+		if err != nil {
+			return xerrors.Errorf("parsing miner %s: %w", m, err)
+		}
+
+		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("getting miner info %s: %w", m, err)
+		}
+
+		switch id {
+		case mi.Owner:
+			return xerrors.Errorf("owner of miner %s", maddr)
+		case mi.Worker:
+			return xerrors.Errorf("worker of miner %s", maddr)
+		case mi.NewWorker:
+			return xerrors.Errorf("pending new worker of miner %s", maddr)
+		}
+		for _, ca := range mi.ControlAddresses {
+			if ca == id {
+				return xerrors.Errorf("control address of miner %s", maddr)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"testing"
+)
+
+func TestDeleteChecks(t *testing.T) {
+	addr := setupTestWallet(t)
+	id := testAddress(t, "f01000")
+	maddr := testAddress(t, "f02420")
+	other := testAddress(t, "f02421")
+
+	node := newTestNode()
+	node.addAccount(addr, id, types.FromFil(1))
+	node.miners[maddr] = &miner.MinerInfo{Owner: testAddress(t, "f01001"), Worker: testAddress(t, "f01001")}
+	node.miners[other] = &miner.MinerInfo{Owner: testAddress(t, "f01001"), Worker: id}
+
+	exists := func() bool {
+		_, err := getAddressInfo(addr.String())
+		return err == nil
+	}
+
+	tests := []struct {
+		args []string
+	}{
+		// 有余额的地址即使指定--force也不能删除
+		{[]string{addr.String()}},
+		{[]string{"--force", addr.String()}},
+	}
+	for _, tc := range tests {
+		if err := runTestCommand(node, deleteCmd, tc.args...); err == nil {
+			t.Errorf("delete %v: expected refusal", tc.args)
+		}
+		if !exists() {
+			t.Fatalf("delete %v: funded address was deleted", tc.args)
+		}
+	}
+
+	// 地址是矿工的worker，不带--force拒绝删除
+	node.actors[addr].Balance = types.NewInt(0)
+	if err := runTestCommand(node, deleteCmd, "--miner", maddr.String(), "--miner", other.String(), addr.String()); err == nil || !exists() {
+		t.Fatal("worker address must not be deleted without --force")
+	}
+	if err := runTestCommand(node, deleteCmd, "--force", "--miner", other.String(), addr.String()); err != nil {
+		t.Fatal(err)
+	}
+	if exists() {
+		t.Fatal("address was not deleted with --force")
+	}
+
+	// 检查通过时直接删除
+	addr = setupTestWallet(t)
+	node.addAccount(addr, id, types.NewInt(0))
+	node.miners[other].Worker = testAddress(t, "f01001")
+	if err := runTestCommand(node, deleteCmd, "--miner", maddr.String(), addr.String()); err != nil {
+		t.Fatal(err)
+	}
+	if exists() {
+		t.Fatal("unused address was not deleted")
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/market"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	market2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/market"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var marketCmd = &cli.Command{
+	Name:  "market",
+	Usage: "管理存储市场托管余额，客户地址和矿工都可以使用",
+	Subcommands: []*cli.Command{
+		marketAddCmd,
+		marketWithdrawCmd,
+	},
+}
+
+var marketAddCmd = &cli.Command{
+	Name:      "add",
+	Usage:     "向存储市场托管余额充值，例如 market add f02420 10",
+	ArgsUsage: "[客户地址或矿工ID] [amount (FIL)]",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "付款地址，默认客户地址为自身，矿工为worker地址",
+		},
+		msigSignerFlag,
+		yesFlag,
+		waitFlag,
+		confidenceFlag,
+	}, msgSendFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+		if cctx.NArg() != 2 {
+			fmt.Println("必须输入充值地址和金额")
+			return fmt.Errorf("must pass address and amount")
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			fmt.Printf("输入地址(%s)不正确。 %v\n", cctx.Args().First(), err)
+			return err
+		}
+
+		amount, err := types.ParseFIL(cctx.Args().Get(1))
+		if err != nil {
+			fmt.Printf("解析金额失败: %v\n", err)
+			return err
+		}
+
+		owner, worker, err := marketParties(ctx, api, addr)
+		if err != nil {
+			fmt.Printf("读取地址(%s)链上状态失败，err:%v\n", addr, err)
+			return err
+		}
+
+		// 任何地址都可以为其他地址充值，默认使用客户地址或矿工的worker付款
+		from := worker
+		if owner == address.Undef {
+			from = addr
+		}
+		if s := cctx.String("from"); s != "" {
+			if from, err = address.NewFromString(s); err != nil {
+				fmt.Printf("解析付款地址失败: %v\n", err)
+				return err
+			}
+		}
+
+		params, err := actors.SerializeParams(&addr)
+		if err != nil {
+			fmt.Printf("序列化充值参数失败，err:%v\n", err)
+			return err
+		}
+
+		cid, wrapped, err := sendAs(cctx, api, from, &types.Message{
+			To:     market.Address,
+			Value:  abi.TokenAmount(amount),
+			Method: market.Methods.AddBalance,
+			Params: params,
+		}, addr)
+		if err != nil {
+			return err
+		}
+		if !cid.Defined() {
+			return nil
+		}
+
+		fmt.Println("Message CID:", cid)
+
+		if cctx.Bool("wait") {
+			wait, err := waitMessage(cctx, api, cid)
+			if err != nil {
+				return err
+			}
+			if wrapped && !msigApplied(cctx, wait) {
+				return nil
+			}
+			printMarketBalance(ctx, api, addr)
+		}
+		return nil
+	},
+}
+
+var marketWithdrawCmd = &cli.Command{
+	Name:      "withdraw",
+	Usage:     "从存储市场托管余额提现，如果不填写金额，则提取所有可用余额。矿工的余额转入owner地址",
+	ArgsUsage: "[客户地址或矿工ID] [amount (FIL)]",
+	Flags: append([]cli.Flag{
+		&cli.BoolFlag{
+			Name:  "worker",
+			Usage: "矿工使用worker地址签名，默认使用owner地址",
+		},
+		yesFlag,
+		waitFlag,
+		confidenceFlag,
+	}, append(msigOwnerFlags, msgSendFlags...)...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+		if !cctx.Args().Present() {
+			fmt.Println("必须输入提现地址")
+			return fmt.Errorf("must pass address")
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			fmt.Printf("输入地址(%s)不正确。 %v\n", cctx.Args().First(), err)
+			return err
+		}
+
+		owner, worker, err := marketParties(ctx, api, addr)
+		if err != nil {
+			fmt.Printf("读取地址(%s)链上状态失败，err:%v\n", addr, err)
+			return err
+		}
+
+		// 客户只能自己提现，矿工需要owner或worker签名，余额转入owner
+		signer, recipient := addr, addr
+		if owner != address.Undef {
+			signer, recipient = owner, owner
+			if cctx.Bool("worker") {
+				signer = worker
+			}
+		} else if cctx.Bool("worker") {
+			fmt.Printf("地址 %s 不是矿工，不能使用 --worker\n", addr)
+			return xerrors.Errorf("%s is not a miner", addr)
+		}
+
+		if cctx.IsSet("approve") {
+			cid, err := approveAs(cctx, api, signer, market.Address, recipient)
+			if err != nil || !cid.Defined() {
+				return err
+			}
+
+			fmt.Println("Message CID:", cid)
+
+			if cctx.Bool("wait") {
+				wait, err := waitMessage(cctx, api, cid)
+				if err != nil {
+					return err
+				}
+				if msigApplied(cctx, wait) {
+					printMarketBalance(ctx, api, addr)
+				}
+			}
+			return nil
+		}
+
+		bal, err := api.StateMarketBalance(ctx, addr, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取market余额失败，err:%v\n", err)
+			return err
+		}
+		available := big.Sub(bal.Escrow, bal.Locked)
+
+		amount := available
+		if cctx.Args().Len() > 1 {
+			f, err := types.ParseFIL(cctx.Args().Get(1))
+			if err != nil {
+				fmt.Printf("解析金额失败: %v\n", err)
+				return err
+			}
+			amount = abi.TokenAmount(f)
+		} else {
+			fmt.Printf("未指定提现金额，将market所有可用余额（%s）提现\n", types.FIL(amount).Short())
+		}
+
+		if amount.LessThanEqual(big.Zero()) {
+			fmt.Println("market没有可用余额")
+			return xerrors.New("no available market balance")
+		}
+		if amount.GreaterThan(available) {
+			fmt.Printf("提现金额%s 超过market可用余额(%s)，已锁定%s，提现失败\n", types.FIL(amount), types.FIL(available), types.FIL(bal.Locked))
+			return xerrors.Errorf("can't withdraw more funds than available; requested: %s; available: %s", amount, available)
+		}
+
+		params, err := actors.SerializeParams(&market2.WithdrawBalanceParams{
+			ProviderOrClientAddress: addr,
+			Amount:                  amount,
+		})
+		if err != nil {
+			fmt.Printf("序列化提现参数失败，err:%v\n", err)
+			return err
+		}
+
+		cid, wrapped, err := sendAs(cctx, api, signer, &types.Message{
+			To:     market.Address,
+			Value:  big.Zero(),
+			Method: market.Methods.WithdrawBalance,
+			Params: params,
+		}, recipient)
+		if err != nil {
+			return err
+		}
+		if !cid.Defined() {
+			return nil
+		}
+
+		fmt.Println("Message CID:", cid)
+
+		if cctx.Bool("wait") {
+			wait, err := waitMessage(cctx, api, cid)
+			if err != nil {
+				return err
+			}
+			if wrapped && !msigApplied(cctx, wait) {
+				return nil
+			}
+			printMarketBalance(ctx, api, addr)
+		}
+		return nil
+	},
+}
+
+// 地址为矿工时返回owner和worker的ID地址，客户地址返回Undef
+func marketParties(ctx context.Context, api v0api.FullNode, addr address.Address) (address.Address, address.Address, error) {
+	act, err := api.StateGetActor(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return address.Undef, address.Undef, err
+	}
+	if !builtin.IsStorageMinerActor(act.Code) {
+		return address.Undef, address.Undef, nil
+	}
+
+	mi, err := api.StateMinerInfo(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return address.Undef, address.Undef, err
+	}
+	return mi.Owner, mi.Worker, nil
+}
+
+func printMarketBalance(ctx context.Context, api v0api.FullNode, addr address.Address) {
+	bal, err := api.StateMarketBalance(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		fmt.Printf("读取market余额失败，err:%v\n", err)
+		return
+	}
+	fmt.Printf("market余额: 可用 %s，锁定 %s\n", types.FIL(big.Sub(bal.Escrow, bal.Locked)), types.FIL(bal.Locked))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"github.com/filecoin-project/go-state-types/big"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/market"
+	"github.com/filecoin-project/lotus/chain/types"
+	market2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/market"
+	"testing"
+)
+
+func TestMarketAddWithdraw(t *testing.T) {
+	from := setupTestWallet(t)
+	id := testAddress(t, "f01000")
+	suffix := from.String()[len(from.String())-4:]
+
+	node := newTestNode()
+	node.addAccount(from, id, types.FromFil(100))
+	node.market[id] = lapi.MarketBalance{Escrow: types.FromFil(3), Locked: types.FromFil(1)}
+
+	// 接收地址是f05，确认的是充值地址
+	withStdin(t, suffix+"\n")
+	if err := runTestCommand(node, marketAddCmd, from.String(), "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	withStdin(t, suffix+"\n")
+	if err := runTestCommand(node, marketWithdrawCmd, from.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runTestCommand(node, marketWithdrawCmd, "--yes", from.String(), "3"); err == nil {
+		t.Fatal("expected error withdrawing more than available")
+	}
+
+	if len(node.pushed) != 2 {
+		t.Fatalf("expected 2 pushed messages, got %d", len(node.pushed))
+	}
+
+	add := node.pushed[0].Message
+	if add.To != market.Address || add.Method != market.Methods.AddBalance || !add.Value.Equals(types.FromFil(2)) {
+		t.Errorf("unexpected add message: %s.%d value %s", add.To, add.Method, types.FIL(add.Value))
+	}
+
+	withdraw := node.pushed[1].Message
+	if withdraw.To != market.Address || withdraw.Method != market.Methods.WithdrawBalance || !withdraw.Value.IsZero() {
+		t.Errorf("unexpected withdraw message: %s.%d value %s", withdraw.To, withdraw.Method, types.FIL(withdraw.Value))
+	}
+
+	var params market2.WithdrawBalanceParams
+	if err := params.UnmarshalCBOR(bytes.NewReader(withdraw.Params)); err != nil {
+		t.Fatal(err)
+	}
+	if params.ProviderOrClientAddress != from || !params.Amount.Equals(big.Sub(types.FromFil(3), types.FromFil(1))) {
+		t.Errorf("unexpected withdraw params: %s %s", params.ProviderOrClientAddress, types.FIL(params.Amount))
+	}
+}
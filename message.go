@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/specs-actors/v5/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+)
+
+// 离线签名消息文件的格式版本
+const messageFileVersion = 1
+
+// 离线签名流程中在机器之间传递的消息文件
+type messageFile struct {
+	Version int
+	// 构造消息的节点所在网络，例如 mainnet
+	Network string
+	// 地址网络前缀，f 或 t
+	Prefix    string
+	Cid       cid.Cid
+	Message   *types.Message
+	Signature *crypto.Signature `json:",omitempty"`
+	// 签名前需要输入确认的地址，为空时确认接收地址
+	Confirm string `json:",omitempty"`
+}
+
+var unsignedOutFlag = &cli.StringFlag{
+	Name:  "unsigned-out",
+	Usage: "只评估gas并将未签名消息写入文件，不需要解锁钱包。在离线机器上用sign-message-file签名后，再用push推送",
+}
+
+// 发送消息类命令的Before，指定--unsigned-out或--dry-run时无需解锁钱包，只打开数据库记录nonce
+func initForSend(cctx *cli.Context) error {
+	if cctx.IsSet("unsigned-out") || cctx.Bool("dry-run") {
+		return _initDb()
+	}
+
+	if err := _init(); err != nil {
+		passwdValid = false
+	}
+	return nil
+}
+
+// 评估gas、签名并推送消息。指定--dry-run时只模拟执行，指定--unsigned-out时只将评估后的消息写入文件，都返回cid.Undef。
+// confirm为签名前需要输入确认的地址，address.Undef表示接收地址
+func sendMessage(cctx *cli.Context, api v0api.FullNode, msg *types.Message, confirm address.Address) (cid.Cid, error) {
+	ctx := lcli.ReqContext(cctx)
+
+	msg, err := estimateMessageGas(cctx, api, msg)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	previewMessage(ctx, api, msg)
+
+	if confirm == address.Undef {
+		confirm = msg.To
+	}
+
+	if cctx.Bool("dry-run") {
+		return cid.Undef, dryRunMessage(cctx, api, msg)
+	}
+
+	if out := cctx.String("unsigned-out"); out != "" {
+		nn, err := api.StateNetworkName(ctx)
+		if err != nil {
+			fmt.Printf("读取节点网络名称失败，err:%v\n", err)
+			return cid.Undef, err
+		}
+
+		mf := &messageFile{Network: string(nn), Message: msg}
+		if confirm != msg.To {
+			mf.Confirm = confirm.String()
+		}
+		if err := writeMessageFile(out, mf); err != nil {
+			fmt.Printf("写入消息文件失败，err:%v\n", err)
+			return cid.Undef, err
+		}
+
+		// 离线签名的消息还未推送，本地先占用该nonce
+		if err := recordNonce(ctx, api, msg, msg.Cid()); err != nil {
+			fmt.Printf("保存nonce记录失败，err:%v\n", err)
+			return cid.Undef, err
+		}
+
+		fmt.Printf("未签名消息已写入 %s，消息CID: %s\n", out, msg.Cid())
+		return cid.Undef, nil
+	}
+
+	// 转账和调用actor方法（提现、更换owner/worker等）前需要输入地址确认，只有不转账的Send消息（例如填补nonce）不需要确认
+	if (msg.Method != builtin.MethodSend || (!msg.Value.Nil() && !msg.Value.IsZero())) && !cctx.Bool("yes") {
+		if err := confirmMessage(confirm); err != nil {
+			return cid.Undef, err
+		}
+	}
+
+	sm, err := signChainMessage(lcli.ReqContext(cctx), api, msg)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	// 推送消息
+	return pushMessage(cctx, api, sm)
+}
+
+// 检查转账策略后使用本地私钥签名链上消息，离线签名时api为nil
+func signChainMessage(ctx context.Context, api v0api.FullNode, msg *types.Message) (*types.SignedMessage, error) {
+	if err := enforcePolicy(ctx, api, msg); err != nil {
+		return nil, err
+	}
+
+	mb, err := msg.ToStorageBlock()
+	if err != nil {
+		fmt.Printf("序列化消息失败， err:%v\n", err)
+		return nil, xerrors.Errorf("serializing message: %w", err)
+	}
+
+	sb, err := signMessage(mb.Cid().Bytes(), msg.From)
+	if err != nil {
+		fmt.Printf("签名失败， err:%v\n", err)
+		return nil, xerrors.Errorf("签名失败: %w", err)
+	}
+
+	return &types.SignedMessage{Message: *msg, Signature: *sb}, nil
+}
+
+func addressPrefix() string {
+	if address.CurrentNetwork == address.Mainnet {
+		return address.MainnetPrefix
+	}
+	return address.TestnetPrefix
+}
+
+func writeMessageFile(path string, mf *messageFile) error {
+	mf.Version = messageFileVersion
+	mf.Prefix = addressPrefix()
+	mf.Cid = mf.Message.Cid()
+
+	b, err := json.MarshalIndent(mf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// 读取消息文件，并检查消息CID和地址网络是否一致
+func readMessageFile(path string) (*messageFile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mf := new(messageFile)
+	if err := json.Unmarshal(b, mf); err != nil {
+		return nil, xerrors.Errorf("decoding message file: %w", err)
+	}
+
+	if mf.Version != messageFileVersion {
+		return nil, xerrors.Errorf("unsupported message file version: %d", mf.Version)
+	}
+	if mf.Message == nil {
+		return nil, xerrors.New("message file contains no message")
+	}
+	if c := mf.Message.Cid(); c != mf.Cid {
+		return nil, xerrors.Errorf("message cid mismatch: file says %s, message is %s", mf.Cid, c)
+	}
+	if mf.Prefix != addressPrefix() {
+		return nil, xerrors.Errorf("message built for network prefix '%s', this wallet uses '%s'", mf.Prefix, addressPrefix())
+	}
+
+	return mf, nil
+}
+
+var signMessageFileCmd = &cli.Command{
+	Name:      "sign-message-file",
+	Usage:     "在离线机器上签名--unsigned-out生成的消息文件",
+	ArgsUsage: "<unsigned message file>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "签名后的消息文件路径，默认为 <unsigned message file>.signed",
+		},
+		maxFeeFlag,
+	},
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定未签名的消息文件")
+			return fmt.Errorf("must pass unsigned message file")
+		}
+
+		in := cctx.Args().First()
+		mf, err := readMessageFile(in)
+		if err != nil {
+			fmt.Printf("读取消息文件失败，err:%v\n", err)
+			return err
+		}
+
+		if mf.Signature != nil {
+			fmt.Println("消息文件已经签名")
+			return xerrors.New("message file is already signed")
+		}
+
+		fmt.Printf("网络: %s\n", mf.Network)
+		previewMessage(lcli.ReqContext(cctx), nil, mf.Message)
+
+		maxFee, err := getMaxFee(cctx)
+		if err != nil {
+			fmt.Printf("读取最大手续费失败， err:%v\n", err)
+			return err
+		}
+		if err := checkMaxFee(mf.Message, maxFee); err != nil {
+			fmt.Printf("最大手续费超过限制，拒绝签名: %v\n", err)
+			return err
+		}
+
+		confirm := mf.Message.To
+		if mf.Confirm != "" {
+			if confirm, err = address.NewFromString(mf.Confirm); err != nil {
+				fmt.Printf("解析消息文件中的确认地址失败， err:%v\n", err)
+				return err
+			}
+		}
+		if err := confirmMessage(confirm); err != nil {
+			return err
+		}
+
+		sm, err := signChainMessage(lcli.ReqContext(cctx), nil, mf.Message)
+		if err != nil {
+			return err
+		}
+		mf.Signature = &sm.Signature
+
+		out := cctx.String("output")
+		if out == "" {
+			out = in + ".signed"
+		}
+
+		if err := writeMessageFile(out, mf); err != nil {
+			fmt.Printf("写入消息文件失败，err:%v\n", err)
+			return err
+		}
+
+		// 签名后的消息在其他机器推送，写出后即计入转账额度
+		if err := recordSpend(lcli.ReqContext(cctx), nil, mf.Message); err != nil {
+			fmt.Printf("保存转账记录失败， err:%v\n", err)
+		}
+
+		fmt.Printf("签名后的消息已写入 %s，消息CID: %s\n", out, mf.Cid)
+		return nil
+	},
+}
+
+var pushCmd = &cli.Command{
+	Name:      "push",
+	Usage:     "推送sign-message-file签名后的消息，指定--dry-run时只模拟执行不推送",
+	ArgsUsage: "<signed message file>",
+	Flags: []cli.Flag{
+		waitFlag,
+		confidenceFlag,
+	},
+	Before: func(context *cli.Context) error {
+		return _initDb()
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定签名后的消息文件")
+			return fmt.Errorf("must pass signed message file")
+		}
+
+		mf, err := readMessageFile(cctx.Args().First())
+		if err != nil {
+			fmt.Printf("读取消息文件失败，err:%v\n", err)
+			return err
+		}
+
+		if mf.Signature == nil {
+			fmt.Println("消息文件未签名，请先执行sign-message-file")
+			return xerrors.New("message file is not signed")
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		nn, err := api.StateNetworkName(ctx)
+		if err != nil {
+			fmt.Printf("读取节点网络名称失败，err:%v\n", err)
+			return err
+		}
+		if string(nn) != mf.Network {
+			fmt.Printf("消息是为网络 %s 构造的，当前节点网络为 %s\n", mf.Network, nn)
+			return xerrors.Errorf("network mismatch: message for %s, node on %s", mf.Network, nn)
+		}
+
+		if cctx.Bool("dry-run") {
+			previewMessage(ctx, api, mf.Message)
+			return dryRunMessage(cctx, api, mf.Message)
+		}
+
+		c, err := pushMessage(cctx, api, &types.SignedMessage{Message: *mf.Message, Signature: *mf.Signature})
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Message CID:", c)
+
+		if cctx.Bool("wait") {
+			_, err := waitMessage(cctx, api, c)
+			return err
+		}
+		return nil
+	},
+}
+
+var buildMessageCmd = &cli.Command{
+	Name:  "build-message",
+	Usage: "不连接节点，根据命令行参数构造消息，输出消息CID",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "from",
+			Usage:    "发送地址",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "接收地址",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "value",
+			Usage: "转账金额(FIL)",
+			Value: "0",
+		},
+		&cli.Uint64Flag{
+			Name:  "method",
+			Usage: "方法编号",
+			Value: 0,
+		},
+		&cli.StringFlag{
+			Name:  "params-hex",
+			Usage: "hex编码的CBOR参数",
+		},
+		&cli.StringFlag{
+			Name:  "params-base64",
+			Usage: "base64编码的CBOR参数",
+		},
+		&cli.Uint64Flag{
+			Name:     "nonce",
+			Usage:    "消息nonce",
+			Required: true,
+		},
+		&cli.Int64Flag{
+			Name:     "gas-limit",
+			Usage:    "gas limit",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "gas-feecap",
+			Usage:    "gas fee cap (attoFIL)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "gas-premium",
+			Usage:    "gas premium (attoFIL)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "network",
+			Usage: "消息所在网络名称，push时会与节点网络比对",
+			Value: "mainnet",
+		},
+		&cli.StringFlag{
+			Name:  "encoding",
+			Usage: "输出格式: json（可直接用于sign-message-file）, cbor",
+			Value: "json",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "输出文件路径，cbor格式不指定时以hex输出到终端",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		msg, err := buildMessageFromFlags(cctx)
+		if err != nil {
+			fmt.Printf("构造消息失败，err:%v\n", err)
+			return err
+		}
+
+		out := cctx.String("output")
+		switch cctx.String("encoding") {
+		case "json":
+			if out == "" {
+				fmt.Println("json格式必须指定--output")
+				return xerrors.New("--output is required for json encoding")
+			}
+			if err := writeMessageFile(out, &messageFile{Network: cctx.String("network"), Message: msg}); err != nil {
+				fmt.Printf("写入消息文件失败，err:%v\n", err)
+				return err
+			}
+		case "cbor":
+			b, err := msg.Serialize()
+			if err != nil {
+				fmt.Printf("序列化消息失败， err:%v\n", err)
+				return err
+			}
+			if out == "" {
+				fmt.Println(hex.EncodeToString(b))
+			} else if err := ioutil.WriteFile(out, b, 0600); err != nil {
+				fmt.Printf("写入消息文件失败，err:%v\n", err)
+				return err
+			}
+		default:
+			fmt.Println("不支持的输出格式:", cctx.String("encoding"))
+			return xerrors.Errorf("unknown encoding: %s", cctx.String("encoding"))
+		}
+
+		fmt.Println("Message CID:", msg.Cid())
+		return nil
+	},
+}
+
+func buildMessageFromFlags(cctx *cli.Context) (*types.Message, error) {
+	from, err := address.NewFromString(cctx.String("from"))
+	if err != nil {
+		return nil, xerrors.Errorf("parsing from address: %w", err)
+	}
+
+	to, err := address.NewFromString(cctx.String("to"))
+	if err != nil {
+		return nil, xerrors.Errorf("parsing to address: %w", err)
+	}
+
+	value, err := types.ParseFIL(cctx.String("value"))
+	if err != nil {
+		return nil, xerrors.Errorf("parsing value: %w", err)
+	}
+
+	var params []byte
+	switch {
+	case cctx.IsSet("params-hex") && cctx.IsSet("params-base64"):
+		return nil, xerrors.New("--params-hex and --params-base64 are mutually exclusive")
+	case cctx.IsSet("params-hex"):
+		params, err = hex.DecodeString(cctx.String("params-hex"))
+	case cctx.IsSet("params-base64"):
+		params, err = base64.StdEncoding.DecodeString(cctx.String("params-base64"))
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("decoding params: %w", err)
+	}
+
+	feeCap, err := types.BigFromString(cctx.String("gas-feecap"))
+	if err != nil {
+		return nil, xerrors.Errorf("parsing gas fee cap: %w", err)
+	}
+
+	premium, err := types.BigFromString(cctx.String("gas-premium"))
+	if err != nil {
+		return nil, xerrors.Errorf("parsing gas premium: %w", err)
+	}
+
+	if premium.GreaterThan(feeCap) {
+		return nil, xerrors.Errorf("gas premium %s is greater than fee cap %s", premium, feeCap)
+	}
+	if cctx.Int64("gas-limit") <= 0 {
+		return nil, xerrors.New("gas limit must be positive")
+	}
+
+	return &types.Message{
+		Version:    0,
+		To:         to,
+		From:       from,
+		Nonce:      cctx.Uint64("nonce"),
+		Value:      abi.TokenAmount(value),
+		GasLimit:   cctx.Int64("gas-limit"),
+		GasFeeCap:  feeCap,
+		GasPremium: premium,
+		Method:     abi.MethodNum(cctx.Uint64("method")),
+		Params:     params,
+	}, nil
+}
+
+var waitFlag = &cli.BoolFlag{
+	Name:  "wait",
+	Usage: "等待消息上链并输出执行结果，消息执行失败时以非0状态退出",
+}
+
+var confidenceFlag = &cli.Uint64Flag{
+	Name:  "confidence",
+	Usage: "等待消息上链的确认高度",
+	Value: build.MessageConfidence,
+}
+
+// 等待消息上链，输出上链tipset、exit code、gas用量和手续费。消息执行失败时返回错误
+func waitMessage(cctx *cli.Context, api v0api.FullNode, c cid.Cid) (*lapi.MsgLookup, error) {
+	ctx := lcli.ReqContext(cctx)
+
+	fmt.Printf("等待消息 %s 上链...\n", c)
+	wait, err := api.StateWaitMsg(ctx, c, cctx.Uint64("confidence"))
+	if err != nil {
+		fmt.Println("等待消息返回失败,", err)
+		return nil, err
+	}
+
+	if wait.Message != c {
+		fmt.Printf("消息已被替换为 %s\n", wait.Message)
+	}
+	fmt.Printf("上链高度: %d\n", wait.Height)
+	fmt.Printf("上链tipset: %s\n", wait.TipSet)
+	fmt.Printf("ExitCode: %d\n", wait.Receipt.ExitCode)
+	fmt.Printf("GasUsed: %d\n", wait.Receipt.GasUsed)
+
+	res, err := api.StateReplay(ctx, types.EmptyTSK, wait.Message)
+	if err != nil {
+		fmt.Printf("读取消息手续费失败，err:%v\n", err)
+	} else {
+		gc := res.GasCost
+		fmt.Printf("手续费: %s (燃烧 %s, 矿工小费 %s)\n",
+			types.FIL(gc.TotalCost), types.FIL(big.Add(gc.BaseFeeBurn, gc.OverEstimationBurn)), types.FIL(gc.MinerTip))
+	}
+
+	if wait.Receipt.ExitCode != 0 {
+		fmt.Println("消息执行失败!")
+		return wait, xerrors.Errorf("message %s failed with exit code %d", wait.Message, wait.Receipt.ExitCode)
+	}
+
+	return wait, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/urfave/cli/v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPushDryRun(t *testing.T) {
+	from := setupTestWallet(t)
+	to := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+
+	node := newTestNode()
+	node.addAccount(from, testAddress(t, "f01000"), types.FromFil(10))
+
+	dir, err := ioutil.TempDir("", "ff-wallet-push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	msg := &types.Message{From: from, To: to, Value: types.FromFil(1), GasLimit: 1000000, GasFeeCap: big.NewInt(100000), GasPremium: big.NewInt(1000)}
+	path := filepath.Join(dir, "send.json.signed")
+	if err := writeMessageFile(path, &messageFile{
+		Network:   "testnet",
+		Message:   msg,
+		Signature: &crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: make([]byte, 65)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runTestApp(node, []string{"--dry-run"}, pushCmd, path); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.pushed) != 0 || len(node.called) != 1 {
+		t.Fatalf("dry run pushed %d, simulated %d", len(node.pushed), len(node.called))
+	}
+
+	if err := runTestCommand(node, pushCmd, path); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.pushed) != 1 || node.pushed[0].Message.Cid() != msg.Cid() {
+		t.Fatalf("expected signed message to be pushed")
+	}
+}
+
+func TestBuildMessageFromFlags(t *testing.T) {
+	from := "f1os47z7tbjpqw3kt6k77xzmtly5uy4f7nr7k64fi"
+	to := "f02420"
+	base := []string{"--from", from, "--to", to, "--nonce", "7", "--gas-limit", "1000000", "--gas-feecap", "100000", "--gas-premium", "1000"}
+
+	tests := []struct {
+		args   []string
+		value  string
+		method uint64
+		params []byte
+		ok     bool
+	}{
+		{nil, "0", 0, nil, true},
+		{[]string{"--value", "1.5", "--method", "16", "--params-hex", "8142000a"}, "1.5", 16, []byte{0x81, 0x42, 0x00, 0x0a}, true},
+		{[]string{"--params-base64", "gUIACg=="}, "0", 0, []byte{0x81, 0x42, 0x00, 0x0a}, true},
+		{[]string{"--params-hex", "00", "--params-base64", "AA=="}, "", 0, nil, false},
+		{[]string{"--params-hex", "zz"}, "", 0, nil, false},
+		{[]string{"--value", "abc"}, "", 0, nil, false},
+		{[]string{"--to", "bad"}, "", 0, nil, false},
+		{[]string{"--gas-premium", "200000"}, "", 0, nil, false},
+		{[]string{"--gas-limit", "0"}, "", 0, nil, false},
+		{[]string{"--gas-feecap", "x"}, "", 0, nil, false},
+	}
+
+	for _, tc := range tests {
+		var msg *types.Message
+		err := runTestFlags(newTestNode(), buildMessageCmd.Flags, func(cctx *cli.Context) error {
+			var err error
+			msg, err = buildMessageFromFlags(cctx)
+			return err
+		}, append(append([]string{}, base...), tc.args...)...)
+		if (err == nil) != tc.ok {
+			t.Errorf("%v: ok=%v, err=%v", tc.args, tc.ok, err)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+
+		value, _ := types.ParseFIL(tc.value)
+		if msg.From.String() != from || msg.To.String() != to || msg.Nonce != 7 || msg.GasLimit != 1000000 ||
+			!msg.GasFeeCap.Equals(big.NewInt(100000)) || !msg.GasPremium.Equals(big.NewInt(1000)) {
+			t.Errorf("%v: unexpected message %+v", tc.args, msg)
+		}
+		if !msg.Value.Equals(abi.TokenAmount(value)) || uint64(msg.Method) != tc.method || !bytes.Equal(msg.Params, tc.params) {
+			t.Errorf("%v: value %s method %d params %x", tc.args, types.FIL(msg.Value), msg.Method, msg.Params)
+		}
+	}
+}
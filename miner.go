@@ -1,34 +1,40 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/filecoin-project/lotus/lib/tablewriter"
 	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
 	"os"
 	"strings"
+	"time"
 )
 
 var withdrawCmd = &cli.Command{
 	Name:      "withdraw",
 	Usage:     "矿工提现,例如 withdraw f02420 100, 如果不填写提现金额，则提取miner所有余额",
 	ArgsUsage: "[minerId (eg f01000) ] [amount (FIL)]",
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Flags: append([]cli.Flag{
+		yesFlag,
+		waitFlag,
+		confidenceFlag,
+	}, append(msigOwnerFlags, msgSendFlags...)...),
+	Before: initForSend,
 	Action: func(cctx *cli.Context) error {
 		/**
 		1 获取nonce，
@@ -63,12 +69,24 @@ var withdrawCmd = &cli.Command{
 			return err
 		}
 
-		owner, err := api.StateAccountKey(ctx, mi.Owner, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("%s\t%s: error getting account key: %s\n", "owner", owner, err)
-			return err
+		// owner为多签钱包时，其他签名人批准提现交易
+		if cctx.IsSet("approve") {
+			cid, err := approveAs(cctx, api, mi.Owner, maddr, mi.Owner)
+			if err != nil || !cid.Defined() {
+				return err
+			}
+
+			fmt.Println("Message CID:", cid)
+
+			if cctx.Bool("wait") {
+				wait, err := waitMessage(cctx, api, cid)
+				if err != nil {
+					return err
+				}
+				msigApplied(cctx, wait)
+			}
+			return nil
 		}
-		//fmt.Printf("--->%+v", owner)
 
 		// 获取矿工可用余额
 		available, err := api.StateMinerAvailableBalance(ctx, maddr, types.EmptyTSK)
@@ -91,13 +109,6 @@ var withdrawCmd = &cli.Command{
 			return xerrors.Errorf("can't withdraw more funds than available; requested: %s; available: %s", amount, available)
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, mi.Owner, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-			return err
-		}
-
 		params, err := actors.SerializeParams(&miner2.WithdrawBalanceParams{
 			AmountRequested: amount, // Default to attempting to withdraw all the extra funds in the miner actor
 		})
@@ -106,91 +117,70 @@ var withdrawCmd = &cli.Command{
 			return err
 		}
 
-		msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
+		cid, wrapped, err := sendAs(cctx, api, mi.Owner, &types.Message{
 			To:     maddr,
-			From:   owner,
 			Value:  types.NewInt(0),
 			Method: miner.Methods.WithdrawBalance,
-			Nonce:  a.Nonce,
 			Params: params,
-		}, nil, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
-			return xerrors.Errorf("GasEstimateMessageGas error: %w", err)
-		}
-
-		fmt.Printf("\n%+v\n", msg)
-
-		mb, err := msg.ToStorageBlock()
-		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v", err)
-			return xerrors.Errorf("serializing message: %w", err)
-		}
-
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
+		}, mi.Owner)
 		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
-
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
+		if !cid.Defined() {
+			return nil
+		}
 
 		fmt.Printf("Requested rewards withdrawal in message %s\n", cid.String())
 
+		if cctx.Bool("wait") {
+			wait, err := waitMessage(cctx, api, cid)
+			if err != nil {
+				return err
+			}
+			if wrapped {
+				msigApplied(cctx, wait)
+			}
+		}
 		return nil
 	},
 }
 
 var controlSetCmd = &cli.Command{
 	Name:      "control-set",
-	Usage:     "Set control address(-es)",
+	Usage:     "设置矿工的control地址，使用owner签名，未列出的现有control地址会被删除",
 	ArgsUsage: "[minerId (eg. f021704)] [...address]",
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
-	Flags: []cli.Flag{
-		&cli.BoolFlag{
-			Name:  "really-do-it",
-			Usage: "Actually send transaction performing the action",
-			Value: false,
-		},
-	},
+	Flags: append([]cli.Flag{
+		confidenceFlag,
+	}, append(msigOwnerFlags, msgSendFlags...)...),
+	Before: initForSend,
 	Action: func(cctx *cli.Context) error {
-		//nodeApi, closer, err := GetStorageMinerAPI(cctx)
-		//if err != nil {
-		//	return err
-		//}
-		//defer closer()
 		if !passwdValid {
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
+		if !cctx.Args().Present() {
+			fmt.Println("必须输入矿工编号")
+			return fmt.Errorf("must pass miner id")
+		}
 
 		api, acloser, err := lcli.GetFullNodeAPI(cctx)
 		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 			return err
 		}
 		defer acloser()
 
 		ctx := lcli.ReqContext(cctx)
 
-		//maddr, err := nodeApi.ActorAddress(ctx)
 		maddr, err := address.NewFromString(cctx.Args().First())
 		if err != nil {
+			fmt.Println("解析矿工地址失败", err)
 			return err
 		}
 
 		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
 		if err != nil {
+			fmt.Println("从链上读取矿工状态失败", err)
 			return err
 		}
 
@@ -199,6 +189,7 @@ var controlSetCmd = &cli.Command{
 		for _, controlAddress := range mi.ControlAddresses {
 			ka, err := api.StateAccountKey(ctx, controlAddress, types.EmptyTSK)
 			if err != nil {
+				fmt.Printf("读取control地址(%s)的公钥地址失败，err:%v\n", controlAddress, err)
 				return err
 			}
 
@@ -207,132 +198,134 @@ var controlSetCmd = &cli.Command{
 		}
 
 		var toSet []address.Address
+		var changed bool
 
 		for i, as := range cctx.Args().Tail() {
 			a, err := address.NewFromString(as)
 			if err != nil {
+				fmt.Printf("解析地址(%s)失败，err:%v\n", as, err)
 				return xerrors.Errorf("parsing address %d: %w", i, err)
 			}
 
+			// 地址必须已经在链上
 			ka, err := api.StateAccountKey(ctx, a, types.EmptyTSK)
 			if err != nil {
-				return err
-			}
-
-			// make sure the address exists on chain
-			_, err = api.StateLookupID(ctx, ka, types.EmptyTSK)
-			if err != nil {
-				return xerrors.Errorf("looking up %s: %w", ka, err)
+				fmt.Printf("读取地址(%s)链上状态失败，control地址需要先有链上转账，err:%v\n", a, err)
+				return xerrors.Errorf("looking up %s: %w", a, err)
 			}
 
 			delete(del, ka)
 			toSet = append(toSet, ka)
+			if _, exists := existing[ka]; !exists {
+				fmt.Println("Add", ka)
+				changed = true
+			}
 		}
 
 		for a := range del {
 			fmt.Println("Remove", a)
-		}
-		for _, a := range toSet {
-			if _, exists := existing[a]; !exists {
-				fmt.Println("Add", a)
-			}
+			changed = true
 		}
 
-		if !cctx.Bool("really-do-it") {
-			fmt.Println("Pass --really-do-it to actually execute this action")
-			return nil
-		}
-
-		cwp := &miner2.ChangeWorkerAddressParams{
+		sp, err := actors.SerializeParams(&miner2.ChangeWorkerAddressParams{
 			NewWorker:       mi.Worker,
 			NewControlAddrs: toSet,
-		}
-
-		sp, err := actors.SerializeParams(cwp)
+		})
 		if err != nil {
+			fmt.Println("序列化消息参数失败", err)
 			return xerrors.Errorf("serializing params: %w", err)
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, mi.Owner, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-			return err
+		// 确认新的control地址列表中的最后一个地址，删除全部control地址时确认矿工地址
+		confirm := maddr
+		if len(toSet) > 0 {
+			confirm = toSet[len(toSet)-1]
 		}
 
-		owner, err := api.StateAccountKey(ctx, mi.Owner, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("%s\t%s: error getting account key: %s\n", "owner", owner, err)
-			return err
+		// owner为多签钱包时，其他签名人批准设置control地址的交易，交易必须设置命令中列出的control地址
+		if cctx.IsSet("approve") {
+			txn, err := msigPendingTxn(ctx, api, mi.Owner, cctx.Uint64("approve"))
+			if err != nil {
+				fmt.Printf("读取多签交易失败，err:%v\n", err)
+				return err
+			}
+			if txn.Method != miner.Methods.ChangeWorkerAddress || !bytes.Equal(txn.Params, sp) {
+				fmt.Printf("多签交易 %d 设置的control地址与命令中列出的地址不一致\n", cctx.Uint64("approve"))
+				return xerrors.Errorf("transaction %d does not set the listed control addresses", cctx.Uint64("approve"))
+			}
+
+			cid, err := approveAs(cctx, api, mi.Owner, maddr, confirm)
+			if err != nil || !cid.Defined() {
+				return err
+			}
+
+			fmt.Println("Message CID:", cid)
+
+			wait, err := waitMessage(cctx, api, cid)
+			if err != nil {
+				fmt.Println("设置control地址失败!")
+				return err
+			}
+			if msigApplied(cctx, wait) {
+				fmt.Println("消息发送成功！")
+			}
+			return nil
 		}
 
-		msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
+		if !changed {
+			fmt.Println("control地址没有变化")
+			return nil
+		}
+
+		cid, wrapped, err := sendAs(cctx, api, mi.Owner, &types.Message{
 			To:     maddr,
-			From:   owner,
 			Value:  big.Zero(),
 			Method: miner.Methods.ChangeWorkerAddress,
-			Nonce:  a.Nonce,
 			Params: sp,
-		}, nil, types.EmptyTSK)
+		}, confirm)
 		if err != nil {
-			fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
-			return xerrors.Errorf("GasEstimateMessageGas error: %w", err)
+			return err
 		}
-
-		fmt.Printf("\n%+v\n", msg)
-
-		mb, err := msg.ToStorageBlock()
-		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v", err)
-			return xerrors.Errorf("serializing message: %w", err)
+		if !cid.Defined() {
+			return nil
 		}
 
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
+		fmt.Println("Message CID:", cid)
 
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
+		// wait for it to get mined into a block
+		wait, err := waitMessage(cctx, api, cid)
 		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
+			fmt.Println("设置control地址失败!")
 			return err
 		}
+		if wrapped && !msigApplied(cctx, wait) {
+			return nil
+		}
 
-		fmt.Println("Message CID:", cid.String())
+		fmt.Println("消息发送成功！")
 
 		return nil
 	},
 }
+
 var setOwnerCmd = &cli.Command{
 	Name:      "set-owner",
 	Usage:     "设置矿工的owner地址 (设置过程中这个命令需要被执行两次, 第一次用旧的ownr地址发送, 第二次用新的owner地址发送)",
 	ArgsUsage: "[miner 新owner地址 发送地址]",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.BoolFlag{
-			Name:  "really-do-it",
-			Usage: "确定命令，防止误操作",
-			Value: false,
+			Name:   "really-do-it",
+			Usage:  "已废弃，签名前需要输入接收地址确认",
+			Hidden: true,
 		},
-	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+		confidenceFlag,
+	}, append(msigOwnerFlags, msgSendFlags...)...),
+	Before: initForSend,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		if !cctx.Bool("really-do-it") {
-			fmt.Println("Pass --really-do-it to actually execute this action")
-			return nil
-		}
-
 		if cctx.NArg() != 3 {
 			fmt.Println("必须输入矿工编号，新的owner地址，和发送钱包地址")
 			return fmt.Errorf("must pass miner id, new owner address and sender address")
@@ -395,68 +388,58 @@ var setOwnerCmd = &cli.Command{
 			return xerrors.New("from address must either be the old owner or the new owner")
 		}
 
+		// 发送地址为多签钱包时，其他签名人批准修改owner的交易
+		if cctx.IsSet("approve") {
+			cid, err := approveAs(cctx, api, fromAddrId, maddr, na)
+			if err != nil || !cid.Defined() {
+				return err
+			}
+
+			fmt.Println("Message CID:", cid)
+
+			wait, err := waitMessage(cctx, api, cid)
+			if err != nil {
+				fmt.Println("发送修改owner地址失败!")
+				return err
+			}
+			if !msigApplied(cctx, wait) {
+				return nil
+			}
+
+			fmt.Println("消息发送成功！")
+			return nil
+		}
+
 		sp, err := actors.SerializeParams(&newAddrId)
 		if err != nil {
 			fmt.Println("序列化发送参数失败", err)
 			return xerrors.Errorf("serializing params: %w", err)
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, fromAddrId, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-			return err
-		}
-
-		msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
-			From:   fa,
+		cid, wrapped, err := sendAs(cctx, api, fromAddrId, &types.Message{
 			To:     maddr,
 			Method: miner.Methods.ChangeOwnerAddress,
 			Value:  big.Zero(),
 			Params: sp,
-			Nonce:  a.Nonce,
-		}, nil, types.EmptyTSK)
-		if err != nil {
-			fmt.Println("评估消息gas失败，", err)
-			return xerrors.Errorf("mpool push: %w", err)
-		}
-
-		fmt.Printf("\n%+v\n", msg)
-
-		mb, err := msg.ToStorageBlock()
+		}, na)
 		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v", err)
-			return xerrors.Errorf("serializing message: %w", err)
-		}
-
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
-
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
+		if !cid.Defined() {
+			return nil
+		}
 
 		fmt.Println("Message CID:", cid)
 
 		// wait for it to get mined into a block
-		wait, err := api.StateWaitMsg(ctx, cid, build.MessageConfidence)
+		wait, err := waitMessage(cctx, api, cid)
 		if err != nil {
-			fmt.Println("等效消息返回失败,", err)
-			return err
-		}
-
-		// check it executed successfully
-		if wait.Receipt.ExitCode != 0 {
 			fmt.Println("发送修改owner地址失败!")
 			return err
 		}
+		if wrapped && !msigApplied(cctx, wait) {
+			return nil
+		}
 
 		fmt.Println("消息发送成功！")
 
@@ -466,7 +449,7 @@ var setOwnerCmd = &cli.Command{
 
 var controlListCmd = &cli.Command{
 	Name:      "control-list",
-	Usage:     "Get currently set control addresses",
+	Usage:     "查看矿工的owner、worker和control地址，以及私钥是否在本钱包中",
 	ArgsUsage: "[minerId]",
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
@@ -478,39 +461,34 @@ var controlListCmd = &cli.Command{
 		},
 	},
 	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
+		return _initDb()
 	},
 	Action: func(cctx *cli.Context) error {
 		color.NoColor = !cctx.Bool("color")
-		if !passwdValid {
-			fmt.Println("密码错误.")
-			return fmt.Errorf("密码错误")
+
+		if !cctx.Args().Present() {
+			fmt.Println("必须输入矿工编号")
+			return fmt.Errorf("must pass miner id")
 		}
-		//nodeApi, closer, err := GetStorageMinerAPI(cctx)
-		//if err != nil {
-		//	return err
-		//}
-		//defer closer()
 
 		api, acloser, err := lcli.GetFullNodeAPI(cctx)
 		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 			return err
 		}
 		defer acloser()
 
 		ctx := lcli.ReqContext(cctx)
 
-		//maddr, err := nodeApi.ActorAddress(ctx)
 		maddr, err := address.NewFromString(cctx.Args().First())
 		if err != nil {
+			fmt.Println("解析矿工地址失败", err)
 			return err
 		}
 
 		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
 		if err != nil {
+			fmt.Println("从链上读取矿工状态失败", err)
 			return err
 		}
 
@@ -518,54 +496,16 @@ var controlListCmd = &cli.Command{
 			tablewriter.Col("name"),
 			tablewriter.Col("ID"),
 			tablewriter.Col("key"),
+			tablewriter.Col("local"),
 			tablewriter.Col("use"),
 			tablewriter.Col("balance"),
 		)
 
-		//ac, err := nodeApi.ActorAddressConfig(ctx)
-		//if err != nil {
-		//	return err
-		//}
-
-		commit := map[address.Address]struct{}{}
-		precommit := map[address.Address]struct{}{}
-		terminate := map[address.Address]struct{}{}
 		post := map[address.Address]struct{}{}
-
 		for _, ca := range mi.ControlAddresses {
 			post[ca] = struct{}{}
 		}
 
-		//for _, ca := range ac.PreCommitControl {
-		//	ca, err := api.StateLookupID(ctx, ca, types.EmptyTSK)
-		//	if err != nil {
-		//		return err
-		//	}
-
-		//	delete(post, ca)
-		//	precommit[ca] = struct{}{}
-		//}
-
-		//for _, ca := range ac.CommitControl {
-		//	ca, err := api.StateLookupID(ctx, ca, types.EmptyTSK)
-		//	if err != nil {
-		//		return err
-		//	}
-
-		//	delete(post, ca)
-		//	commit[ca] = struct{}{}
-		//}
-
-		//for _, ca := range ac.TerminateControl {
-		//	ca, err := api.StateLookupID(ctx, ca, types.EmptyTSK)
-		//	if err != nil {
-		//		return err
-		//	}
-
-		//	delete(post, ca)
-		//	terminate[ca] = struct{}{}
-		//}
-
 		printKey := func(name string, a address.Address) {
 			b, err := api.WalletBalance(ctx, a)
 			if err != nil {
@@ -573,14 +513,12 @@ var controlListCmd = &cli.Command{
 				return
 			}
 
-			k, err := api.StateAccountKey(ctx, a, types.EmptyTSK)
-			if err != nil {
-				fmt.Printf("%s\t%s: error getting account key: %s\n", name, a, err)
-				return
+			kstr, held := keyInfo(ctx, api, a)
+			local := ""
+			if held {
+				local = color.GreenString("✓")
 			}
-
-			kstr := k.String()
-			if !cctx.Bool("verbose") {
+			if !cctx.Bool("verbose") && len(kstr) > 12 {
 				kstr = kstr[:9] + "..."
 			}
 
@@ -601,20 +539,12 @@ var controlListCmd = &cli.Command{
 			if _, ok := post[a]; ok {
 				uses = append(uses, color.GreenString("post"))
 			}
-			if _, ok := precommit[a]; ok {
-				uses = append(uses, color.CyanString("precommit"))
-			}
-			if _, ok := commit[a]; ok {
-				uses = append(uses, color.BlueString("commit"))
-			}
-			if _, ok := terminate[a]; ok {
-				uses = append(uses, color.YellowString("terminate"))
-			}
 
 			tw.Write(map[string]interface{}{
 				"name":    name,
 				"ID":      a,
 				"key":     kstr,
+				"local":   local,
 				"use":     strings.Join(uses, " "),
 				"balance": bstr,
 			})
@@ -622,7 +552,9 @@ var controlListCmd = &cli.Command{
 
 		printKey("owner", mi.Owner)
 		printKey("worker", mi.Worker)
-		printKey("newWorker", mi.NewWorker)
+		if !mi.NewWorker.Empty() {
+			printKey("newWorker", mi.NewWorker)
+		}
 		for i, ca := range mi.ControlAddresses {
 			printKey(fmt.Sprintf("control-%d", i), ca)
 		}
@@ -631,23 +563,33 @@ var controlListCmd = &cli.Command{
 	},
 }
 
+// 返回地址的公钥地址（多签钱包返回multisig）和私钥是否在本钱包中
+func keyInfo(ctx context.Context, api v0api.FullNode, a address.Address) (string, bool) {
+	k, err := api.StateAccountKey(ctx, a, types.EmptyTSK)
+	if err != nil {
+		if act, aerr := api.StateGetActor(ctx, a, types.EmptyTSK); aerr == nil && builtin.IsMultisigActor(act.Code) {
+			return "multisig", false
+		}
+		return "error: " + err.Error(), false
+	}
+
+	_, err = getAddressInfo(k.String())
+	return k.String(), err == nil
+}
+
 var proposeChangeWorker = &cli.Command{
 	Name:      "propose-change-worker",
 	Usage:     "修改worker钱包地址,（worker的地址必须是bls类型）",
 	ArgsUsage: "[矿工地址, 新worker地址]",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.BoolFlag{
-			Name:  "really-do-it",
-			Usage: "确认执行的命令",
-			Value: false,
+			Name:   "really-do-it",
+			Usage:  "已废弃，签名前需要输入接收地址确认",
+			Hidden: true,
 		},
-	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+		confidenceFlag,
+	}, append(msigOwnerFlags, msgSendFlags...)...),
+	Before: initForSend,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
@@ -661,7 +603,6 @@ var proposeChangeWorker = &cli.Command{
 		api, acloser, err := lcli.GetFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Println("连接FULLNODE_API_INFO失败")
-			return fmt.Errorf("must pass address of new worker address")
 			return err
 		}
 		defer acloser()
@@ -707,97 +648,226 @@ var proposeChangeWorker = &cli.Command{
 			}
 		}
 
-		if !cctx.Bool("really-do-it") {
-			fmt.Fprintln(cctx.App.Writer, "请输入 --really-do-it 参数执行命令")
+		var cid cid.Cid
+		wrapped := cctx.IsSet("approve")
+		if wrapped {
+			// owner为多签钱包时，其他签名人批准修改worker的交易
+			cid, err = approveAs(cctx, api, mi.Owner, maddr, na)
+		} else {
+			cwp := &miner2.ChangeWorkerAddressParams{
+				NewWorker:       newAddr,
+				NewControlAddrs: mi.ControlAddresses,
+			}
+
+			sp, aerr := actors.SerializeParams(cwp)
+			if aerr != nil {
+				fmt.Println("序列化消息参数失败")
+				return xerrors.Errorf("serializing params: %w", aerr)
+			}
+
+			cid, wrapped, err = sendAs(cctx, api, mi.Owner, &types.Message{
+				To:     maddr,
+				Method: miner.Methods.ChangeWorkerAddress,
+				Value:  big.Zero(),
+				Params: sp,
+			}, na)
+		}
+		if err != nil {
+			return err
+		}
+		if !cid.Defined() {
 			return nil
 		}
 
-		cwp := &miner2.ChangeWorkerAddressParams{
-			NewWorker:       newAddr,
-			NewControlAddrs: mi.ControlAddresses,
-		}
+		fmt.Fprintln(cctx.App.Writer, "Propose Message CID:", cid)
 
-		sp, err := actors.SerializeParams(cwp)
+		// wait for it to get mined into a block
+		wait, err := waitMessage(cctx, api, cid)
 		if err != nil {
-			fmt.Println("序列化消息参数失败")
-			return xerrors.Errorf("serializing params: %w", err)
+			fmt.Fprintln(cctx.App.Writer, "Propose worker change failed!")
+			return err
+		}
+		if wrapped && !msigApplied(cctx, wait) {
+			return nil
 		}
 
-		realOwner, err := api.StateAccountKey(ctx, mi.Owner, types.EmptyTSK)
+		mi, err = api.StateMinerInfo(ctx, maddr, wait.TipSet)
 		if err != nil {
-			fmt.Printf("%s\t%s: error getting account key: %s\n", maddr, mi.Owner, err)
-			return nil
+			fmt.Println("获取miner状态失败")
+			return err
+		}
+		if mi.NewWorker != newAddr {
+			fmt.Printf("Proposed worker address change not reflected on chain: expected '%s', found '%s'\n", na, mi.NewWorker)
+			return fmt.Errorf("Proposed worker address change not reflected on chain: expected '%s', found '%s'", na, mi.NewWorker)
+		}
+
+		fmt.Fprintf(cctx.App.Writer, "Worker key change to %s successfully proposed.\n", na)
+		fmt.Fprintf(cctx.App.Writer, "Call 'confirm-change-worker' at or after height %d to complete.\n", mi.WorkerChangeEpoch)
+
+		return nil
+	},
+}
+
+var confirmChangeWorker = &cli.Command{
+	Name:      "confirm-change-worker",
+	Usage:     "确认修改worker钱包地址，需要在propose-change-worker提示的高度之后执行",
+	ArgsUsage: "[矿工地址, 新worker地址]",
+	Flags: append([]cli.Flag{
+		&cli.BoolFlag{
+			Name:  "wait-until-epoch",
+			Usage: "未到生效高度时等待，到达高度后发送确认消息。需要输入的地址确认在等待之前完成",
+		},
+		yesFlag,
+		confidenceFlag,
+	}, append(msigOwnerFlags, msgSendFlags...)...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+		if cctx.NArg() != 2 {
+			fmt.Println("必须输入矿工地址和新worker地址")
+			return fmt.Errorf("must pass miner address and new worker address")
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, mi.Owner, types.EmptyTSK)
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
 		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 			return err
 		}
+		defer acloser()
 
-		msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
-			From:   realOwner,
-			To:     maddr,
-			Method: miner.Methods.ChangeWorkerAddress,
-			Value:  big.Zero(),
-			Params: sp,
-			Nonce:  a.Nonce,
-		}, nil, types.EmptyTSK)
+		ctx := lcli.ReqContext(cctx)
+
+		na, err := address.NewFromString(cctx.Args().Get(1))
 		if err != nil {
-			return xerrors.Errorf("mpool push: %w", err)
+			fmt.Println("解析新的worker地址失败", err)
+			return err
 		}
 
-		fmt.Printf("\n%+v\n", msg)
+		newAddr, err := api.StateLookupID(ctx, na, types.EmptyTSK)
+		if err != nil {
+			fmt.Println("从链上读取新worker地址失败", err)
+			return err
+		}
 
-		mb, err := msg.ToStorageBlock()
+		maddr, err := address.NewFromString(cctx.Args().First())
 		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v", err)
-			return xerrors.Errorf("serializing message: %w", err)
+			fmt.Println("解析矿工地址失败", err)
+			return err
 		}
 
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
+		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
 		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
+			fmt.Println("从链上读取矿工状态失败", err)
+			return err
+		}
+
+		if mi.NewWorker.Empty() {
+			fmt.Println("矿工没有待确认的worker地址修改")
+			return xerrors.New("no worker key change proposed")
+		}
+		if mi.NewWorker != newAddr {
+			fmt.Printf("待确认的worker地址是%s，不是%s\n", mi.NewWorker, newAddr)
+			return xerrors.Errorf("worker key %s does not match current worker key proposal %s", newAddr, mi.NewWorker)
 		}
 
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
+		head, err := api.ChainHead(ctx)
 		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
+			fmt.Println("读取链高度失败", err)
 			return err
 		}
 
-		fmt.Fprintln(cctx.App.Writer, "Propose Message CID:", cid)
+		if head.Height() < mi.WorkerChangeEpoch {
+			if !cctx.Bool("wait-until-epoch") {
+				fmt.Printf("当前高度%d，需要在高度%d之后确认，可以使用 --wait-until-epoch 等待\n", head.Height(), mi.WorkerChangeEpoch)
+				return xerrors.Errorf("worker key change cannot be confirmed until %d, current height is %d", mi.WorkerChangeEpoch, head.Height())
+			}
 
-		// wait for it to get mined into a block
-		wait, err := api.StateWaitMsg(ctx, cid, build.MessageConfidence)
+			// 等待之前确认，到达高度后直接签名发送，无需有人值守
+			if !cctx.Bool("yes") && !cctx.Bool("dry-run") && !cctx.IsSet("unsigned-out") {
+				if err := confirmMessage(na); err != nil {
+					return err
+				}
+				if err := cctx.Set("yes", "true"); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("当前高度%d，等待到达高度%d（约%s）\n", head.Height(), mi.WorkerChangeEpoch,
+				time.Duration(mi.WorkerChangeEpoch-head.Height())*time.Duration(build.BlockDelaySecs)*time.Second)
+			if err := waitUntilEpoch(ctx, api, mi.WorkerChangeEpoch); err != nil {
+				fmt.Println("等待高度失败", err)
+				return err
+			}
+		}
+
+		var cid cid.Cid
+		wrapped := cctx.IsSet("approve")
+		if wrapped {
+			// owner为多签钱包时，其他签名人批准确认worker的交易
+			cid, err = approveAs(cctx, api, mi.Owner, maddr, na)
+		} else {
+			cid, wrapped, err = sendAs(cctx, api, mi.Owner, &types.Message{
+				To:     maddr,
+				Method: miner.Methods.ConfirmUpdateWorkerKey,
+				Value:  big.Zero(),
+			}, na)
+		}
 		if err != nil {
-			fmt.Println("等待消息返回失败")
 			return err
 		}
+		if !cid.Defined() {
+			return nil
+		}
 
-		// check it executed successfully
-		if wait.Receipt.ExitCode != 0 {
-			fmt.Fprintln(cctx.App.Writer, "Propose worker change failed!")
+		fmt.Fprintln(cctx.App.Writer, "Confirm Message CID:", cid)
+
+		// wait for it to get mined into a block
+		wait, err := waitMessage(cctx, api, cid)
+		if err != nil {
+			fmt.Fprintln(cctx.App.Writer, "Worker change failed!")
 			return err
 		}
+		if wrapped && !msigApplied(cctx, wait) {
+			return nil
+		}
 
 		mi, err = api.StateMinerInfo(ctx, maddr, wait.TipSet)
 		if err != nil {
 			fmt.Println("获取miner状态失败")
 			return err
 		}
-		if mi.NewWorker != newAddr {
-			fmt.Printf("Proposed worker address change not reflected on chain: expected '%s', found '%s'\n", na, mi.NewWorker)
-			return fmt.Errorf("Proposed worker address change not reflected on chain: expected '%s', found '%s'", na, mi.NewWorker)
+		if mi.Worker != newAddr {
+			fmt.Printf("Confirmed worker address change not reflected on chain: expected '%s', found '%s'\n", newAddr, mi.Worker)
+			return fmt.Errorf("Confirmed worker address change not reflected on chain: expected '%s', found '%s'", newAddr, mi.Worker)
 		}
 
-		fmt.Fprintf(cctx.App.Writer, "Worker key change to %s successfully proposed.\n", na)
-		fmt.Fprintf(cctx.App.Writer, "Call 'confirm-change-worker' at or after height %d to complete.\n", mi.WorkerChangeEpoch)
+		fmt.Fprintf(cctx.App.Writer, "Worker key change to %s successfully confirmed.\n", na)
 
 		return nil
 	},
 }
+
+// 每个出块周期检查一次链高度，直到到达epoch
+func waitUntilEpoch(ctx context.Context, api v0api.FullNode, epoch abi.ChainEpoch) error {
+	ticker := time.NewTicker(time.Duration(build.BlockDelaySecs) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+		if head.Height() >= epoch {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+	"testing"
+)
+
+func TestConfirmChangeWorkerWait(t *testing.T) {
+	owner := setupTestWallet(t)
+	ownerID := testAddress(t, "f01000")
+	worker := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+	workerID := testAddress(t, "f01001")
+	maddr := testAddress(t, "f02420")
+
+	node := newTestNode()
+	node.addAccount(owner, ownerID, types.FromFil(10))
+	node.addAccount(worker, workerID, types.NewInt(0))
+	node.miners[maddr] = &miner.MinerInfo{Owner: ownerID, Worker: ownerID, NewWorker: workerID, WorkerChangeEpoch: 100}
+	node.onPush = func(sm *types.SignedMessage) {
+		if sm.Message.To == maddr && sm.Message.Method == miner.Methods.ConfirmUpdateWorkerKey {
+			node.miners[maddr].Worker = workerID
+		}
+	}
+
+	// 未到高度且不等待时拒绝执行
+	node.heights = []abi.ChainEpoch{90}
+	if err := runTestCommand(node, confirmChangeWorker, maddr.String(), worker.String()); err == nil {
+		t.Fatal("expected error before change epoch")
+	}
+
+	// 等待前输入确认，到达高度后不再需要输入
+	node.heights = []abi.ChainEpoch{90, 100}
+	withStdin(t, worker.String()[len(worker.String())-4:]+"\n")
+	if err := runTestCommand(node, confirmChangeWorker, "--wait-until-epoch", maddr.String(), worker.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(node.pushed) != 1 {
+		t.Fatalf("expected 1 pushed message, got %d", len(node.pushed))
+	}
+	if m := node.pushed[0].Message; m.From != owner || m.To != maddr || m.Method != miner.Methods.ConfirmUpdateWorkerKey {
+		t.Errorf("unexpected message %s -> %s.%d", m.From, m.To, m.Method)
+	}
+}
+
+func TestControlSetConfirm(t *testing.T) {
+	owner := setupTestWallet(t)
+	ownerID := testAddress(t, "f01000")
+	a := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+	b := testAddress(t, "f1ys5qqiciehcml3sp764ymbbytfn3qoar5fo3iwy")
+	maddr := testAddress(t, "f02420")
+
+	node := newTestNode()
+	node.addAccount(owner, ownerID, types.FromFil(10))
+	node.miners[maddr] = &miner.MinerInfo{Owner: ownerID, Worker: ownerID}
+
+	suffix := func(a string) string {
+		return a[len(a)-4:] + "\n"
+	}
+
+	// worker地址没有变化，确认worker地址不能签名
+	withStdin(t, suffix(owner.String()))
+	if err := runTestCommand(node, controlSetCmd, maddr.String(), a.String(), b.String()); err == nil {
+		t.Fatal("expected confirmation of the worker address to be refused")
+	}
+	if len(node.pushed) != 0 {
+		t.Fatal("unconfirmed message was pushed")
+	}
+
+	// 确认新列表中的最后一个地址
+	withStdin(t, suffix(b.String()))
+	if err := runTestCommand(node, controlSetCmd, maddr.String(), a.String(), b.String()); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.pushed) != 1 {
+		t.Fatalf("expected 1 pushed message, got %d", len(node.pushed))
+	}
+
+	var params miner2.ChangeWorkerAddressParams
+	if err := params.UnmarshalCBOR(bytes.NewReader(node.pushed[0].Message.Params)); err != nil {
+		t.Fatal(err)
+	}
+	if params.NewWorker != ownerID || len(params.NewControlAddrs) != 2 || params.NewControlAddrs[0] != a || params.NewControlAddrs[1] != b {
+		t.Errorf("unexpected params: worker %s controls %v", params.NewWorker, params.NewControlAddrs)
+	}
+
+	// 删除全部control地址时确认矿工地址
+	node.miners[maddr].ControlAddresses = []address.Address{a}
+	withStdin(t, suffix(maddr.String()))
+	if err := runTestCommand(node, controlSetCmd, maddr.String()); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.pushed) != 2 {
+		t.Fatalf("expected 2 pushed messages, got %d", len(node.pushed))
+	}
+}
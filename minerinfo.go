@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"os"
+)
+
+type minerKey struct {
+	Role    string
+	ID      address.Address
+	Key     string
+	Local   bool
+	Balance types.FIL
+}
+
+type minerSummary struct {
+	Miner             address.Address
+	Balance           types.FIL
+	Available         types.FIL
+	Vesting           types.FIL
+	InitialPledge     types.FIL
+	PreCommitDeposits types.FIL
+	FeeDebt           types.FIL
+	RawPower          types.BigInt
+	QAPower           types.BigInt
+	Keys              []minerKey
+}
+
+var minerInfoCmd = &cli.Command{
+	Name:      "miner-info",
+	Usage:     "查看矿工的owner、worker、control地址和可用余额、锁仓、质押、欠款、算力，可以同时查看多个矿工",
+	ArgsUsage: "[minerId...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "输出格式: table, json",
+			Value: "table",
+		},
+		&cli.BoolFlag{
+			Name:  "color",
+			Value: true,
+		},
+	},
+	Before: func(context *cli.Context) error {
+		return _initDb()
+	},
+	Action: func(cctx *cli.Context) error {
+		color.NoColor = !cctx.Bool("color")
+
+		if f := cctx.String("format"); f != "table" && f != "json" {
+			fmt.Println("不支持的输出格式:", f)
+			return xerrors.Errorf("unknown format %s", f)
+		}
+		if !cctx.Args().Present() {
+			fmt.Println("必须输入矿工编号")
+			return fmt.Errorf("must pass miner id")
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		// 单个矿工读取失败时继续读取其他矿工，最后返回错误
+		var summaries []*minerSummary
+		var failed int
+		for _, arg := range cctx.Args().Slice() {
+			maddr, err := address.NewFromString(arg)
+			if err != nil {
+				fmt.Printf("输入miner ID(%s)不正确。 %v\n", arg, err)
+				failed++
+				continue
+			}
+
+			s, err := loadMinerSummary(ctx, api, maddr)
+			if err != nil {
+				fmt.Printf("读取矿工(%s)信息失败。 %v\n", maddr, err)
+				failed++
+				continue
+			}
+			summaries = append(summaries, s)
+		}
+
+		if cctx.String("format") == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(summaries); err != nil {
+				return err
+			}
+		} else if len(summaries) > 0 {
+			if err := printMinerSummaries(summaries); err != nil {
+				return err
+			}
+		}
+
+		if failed > 0 {
+			return xerrors.Errorf("failed to load %d miners", failed)
+		}
+		return nil
+	},
+}
+
+func loadMinerSummary(ctx context.Context, api v0api.FullNode, maddr address.Address) (*minerSummary, error) {
+	mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+
+	act, err := api.StateGetActor(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+
+	store := adt.WrapStore(ctx, cbor.NewCborStore(blockstore.NewAPIBlockstore(api)))
+	mstate, err := miner.Load(store, act)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := mstate.LockedFunds()
+	if err != nil {
+		return nil, err
+	}
+	debt, err := mstate.FeeDebt()
+	if err != nil {
+		return nil, err
+	}
+
+	available, err := api.StateMinerAvailableBalance(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+
+	power, err := api.StateMinerPower(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &minerSummary{
+		Miner:             maddr,
+		Balance:           types.FIL(act.Balance),
+		Available:         types.FIL(available),
+		Vesting:           types.FIL(locked.VestingFunds),
+		InitialPledge:     types.FIL(locked.InitialPledgeRequirement),
+		PreCommitDeposits: types.FIL(locked.PreCommitDeposits),
+		FeeDebt:           types.FIL(debt),
+		RawPower:          power.MinerPower.RawBytePower,
+		QAPower:           power.MinerPower.QualityAdjPower,
+	}
+
+	addKey := func(role string, a address.Address) {
+		k := minerKey{Role: role, ID: a, Balance: types.FIL(big.Zero())}
+		k.Key, k.Local = keyInfo(ctx, api, a)
+		if b, err := api.WalletBalance(ctx, a); err == nil {
+			k.Balance = types.FIL(b)
+		}
+		s.Keys = append(s.Keys, k)
+	}
+
+	addKey("owner", mi.Owner)
+	addKey("worker", mi.Worker)
+	if !mi.NewWorker.Empty() {
+		addKey(fmt.Sprintf("newWorker(%d)", mi.WorkerChangeEpoch), mi.NewWorker)
+	}
+	for i, ca := range mi.ControlAddresses {
+		addKey(fmt.Sprintf("control-%d", i), ca)
+	}
+
+	return s, nil
+}
+
+func printMinerSummaries(summaries []*minerSummary) error {
+	tw := tablewriter.New(
+		tablewriter.Col("Miner"),
+		tablewriter.Col("Balance"),
+		tablewriter.Col("Available"),
+		tablewriter.Col("Vesting"),
+		tablewriter.Col("InitialPledge"),
+		tablewriter.Col("PreCommit"),
+		tablewriter.Col("FeeDebt"),
+		tablewriter.Col("RawPower"),
+		tablewriter.Col("QAPower"),
+	)
+	for _, s := range summaries {
+		debt := s.FeeDebt.Short()
+		if big.Int(s.FeeDebt).GreaterThan(big.Zero()) {
+			debt = color.RedString(debt)
+		}
+		tw.Write(map[string]interface{}{
+			"Miner":         s.Miner,
+			"Balance":       s.Balance.Short(),
+			"Available":     color.GreenString(s.Available.Short()),
+			"Vesting":       s.Vesting.Short(),
+			"InitialPledge": s.InitialPledge.Short(),
+			"PreCommit":     s.PreCommitDeposits.Short(),
+			"FeeDebt":       debt,
+			"RawPower":      types.SizeStr(s.RawPower),
+			"QAPower":       types.SizeStr(s.QAPower),
+		})
+	}
+	if err := tw.Flush(os.Stdout); err != nil {
+		return err
+	}
+
+	fmt.Println()
+
+	kw := tablewriter.New(
+		tablewriter.Col("Miner"),
+		tablewriter.Col("Role"),
+		tablewriter.Col("ID"),
+		tablewriter.Col("Key"),
+		tablewriter.Col("Local"),
+		tablewriter.Col("Balance"),
+	)
+	for _, s := range summaries {
+		for _, k := range s.Keys {
+			local := ""
+			if k.Local {
+				local = color.GreenString("✓")
+			}
+			kw.Write(map[string]interface{}{
+				"Miner":   s.Miner,
+				"Role":    k.Role,
+				"ID":      k.ID,
+				"Key":     k.Key,
+				"Local":   local,
+				"Balance": k.Balance.Short(),
+			})
+		}
+	}
+	return kw.Flush(os.Stdout)
+}
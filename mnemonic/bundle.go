@@ -0,0 +1,306 @@
+package mnemonic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/btcsuite/btcutil/bech32"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// 加密导出文件（bundle）的格式版本。版本2起文件头全部计入AEAD附加数据
+const BundleVersion = 2
+
+const (
+	recipientHRP = "age"
+	identityHRP  = "age-secret-key-"
+	x25519Info   = "ff-wallet/X25519"
+	fileKeySize  = 32
+)
+
+// 解密时允许的scrypt参数上限，防止构造的文件消耗大量内存和CPU（N=2^20, r=8时约需1GiB内存）
+const (
+	maxScryptN = 1 << 20
+	maxScryptR = 8
+	maxScryptP = 1
+)
+
+var ErrNoMatchingKey = errors.New("no passphrase or identity matches this bundle")
+
+// PassphraseStanza 使用一次性口令（scrypt）包裹文件密钥
+type PassphraseStanza struct {
+	Salt       []byte `json:"salt"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	WrappedKey []byte `json:"wrappedKey"`
+}
+
+// RecipientStanza 使用接收方X25519公钥（age风格）包裹文件密钥
+type RecipientStanza struct {
+	Recipient  string `json:"recipient"`
+	Ephemeral  []byte `json:"ephemeral"`
+	WrappedKey []byte `json:"wrappedKey"`
+}
+
+// Bundle 自描述的加密私钥文件，文件中不包含任何明文私钥
+type Bundle struct {
+	Version    int               `json:"version"`
+	Address    string            `json:"address"`
+	Format     string            `json:"format"`
+	Cipher     string            `json:"cipher"`
+	Passphrase *PassphraseStanza `json:"passphrase,omitempty"`
+	Recipients []RecipientStanza `json:"recipients,omitempty"`
+	Nonce      []byte            `json:"nonce"`
+	Payload    []byte            `json:"payload"`
+}
+
+// GenerateIdentity 生成一对X25519密钥，返回私钥（AGE-SECRET-KEY-1...）和公钥（age1...）
+func GenerateIdentity() (string, string, error) {
+	priv := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, priv); err != nil {
+		return "", "", err
+	}
+
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return "", "", err
+	}
+
+	identity, err := encodeBech32(identityHRP, priv)
+	if err != nil {
+		return "", "", err
+	}
+	recipient, err := encodeBech32(recipientHRP, pub)
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.ToUpper(identity), recipient, nil
+}
+
+// SealBundle 加密私钥数据，passphrase和recipients至少指定一个
+func SealBundle(address, format string, plain, passphrase []byte, recipients []string) ([]byte, error) {
+	if len(passphrase) == 0 && len(recipients) == 0 {
+		return nil, errors.New("passphrase or recipients required")
+	}
+
+	fileKey := make([]byte, fileKeySize)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{
+		Version: BundleVersion,
+		Address: address,
+		Format:  format,
+		Cipher:  "chacha20poly1305",
+	}
+
+	if len(passphrase) > 0 {
+		salt := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, err
+		}
+
+		wrapKey, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+		if err != nil {
+			return nil, err
+		}
+
+		wrapped, err := wrapFileKey(wrapKey, fileKey)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Passphrase = &PassphraseStanza{Salt: salt, N: scryptN, R: scryptR, P: scryptP, WrappedKey: wrapped}
+	}
+
+	for _, r := range recipients {
+		pub, err := decodeBech32(recipientHRP, r)
+		if err != nil {
+			return nil, fmt.Errorf("parsing recipient %s: %w", r, err)
+		}
+
+		eph := make([]byte, curve25519.ScalarSize)
+		if _, err := io.ReadFull(rand.Reader, eph); err != nil {
+			return nil, err
+		}
+		ephPub, err := curve25519.X25519(eph, curve25519.Basepoint)
+		if err != nil {
+			return nil, err
+		}
+
+		wrapKey, err := x25519WrapKey(eph, pub, ephPub, pub)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %s: %w", r, err)
+		}
+
+		wrapped, err := wrapFileKey(wrapKey, fileKey)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Recipients = append(b.Recipients, RecipientStanza{Recipient: r, Ephemeral: ephPub, WrappedKey: wrapped})
+	}
+
+	aead, err := chacha20poly1305.New(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	b.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, b.Nonce); err != nil {
+		return nil, err
+	}
+	ad, err := b.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	b.Payload = aead.Seal(nil, b.Nonce, plain, ad)
+
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// ParseBundle 只解析bundle头信息，不解密
+func ParseBundle(data []byte) (*Bundle, error) {
+	b := new(Bundle)
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version: %d", b.Version)
+	}
+	return b, nil
+}
+
+// OpenBundle 使用口令或X25519私钥解密bundle，返回私钥数据
+func OpenBundle(b *Bundle, passphrase []byte, identities []string) ([]byte, error) {
+	var fileKey []byte
+
+	if b.Passphrase != nil && len(passphrase) > 0 {
+		s := b.Passphrase
+		if s.N <= 1 || s.N > maxScryptN || s.R <= 0 || s.R > maxScryptR || s.P <= 0 || s.P > maxScryptP {
+			return nil, fmt.Errorf("scrypt parameters out of range: N=%d r=%d p=%d", s.N, s.R, s.P)
+		}
+		wrapKey, err := scrypt.Key(passphrase, s.Salt, s.N, s.R, s.P, chacha20poly1305.KeySize)
+		if err != nil {
+			return nil, err
+		}
+		fileKey, _ = unwrapFileKey(wrapKey, s.WrappedKey)
+	}
+
+	for _, id := range identities {
+		if fileKey != nil {
+			break
+		}
+
+		priv, err := decodeBech32(identityHRP, id)
+		if err != nil {
+			return nil, fmt.Errorf("parsing identity: %w", err)
+		}
+		pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range b.Recipients {
+			wrapKey, err := x25519WrapKey(priv, s.Ephemeral, s.Ephemeral, pub)
+			if err != nil {
+				continue
+			}
+			if fileKey, err = unwrapFileKey(wrapKey, s.WrappedKey); err == nil {
+				break
+			}
+		}
+	}
+
+	if fileKey == nil {
+		return nil, ErrNoMatchingKey
+	}
+
+	aead, err := chacha20poly1305.New(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	ad, err := b.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, b.Nonce, b.Payload, ad)
+}
+
+// 加密时的附加数据：除nonce和密文之外的文件头（地址、格式、口令参数和接收方），修改任何一项都无法解密
+func (b *Bundle) additionalData() ([]byte, error) {
+	h := *b
+	h.Nonce, h.Payload = nil, nil
+	return json.Marshal(&h)
+}
+
+func x25519WrapKey(priv, peer, ephPub, recipientPub []byte) ([]byte, error) {
+	shared, err := curve25519.X25519(priv, peer)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 0, len(ephPub)+len(recipientPub))
+	salt = append(salt, ephPub...)
+	salt = append(salt, recipientPub...)
+
+	wrapKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519Info)), wrapKey); err != nil {
+		return nil, err
+	}
+	return wrapKey, nil
+}
+
+// 每个包裹密钥只使用一次，因此可以使用全零nonce
+func wrapFileKey(wrapKey, fileKey []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil), nil
+}
+
+func unwrapFileKey(wrapKey, wrapped []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, make([]byte, aead.NonceSize()), wrapped, nil)
+}
+
+func encodeBech32(hrp string, data []byte) (string, error) {
+	conv, err := bech32.ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return bech32.Encode(hrp, conv)
+}
+
+func decodeBech32(hrp, s string) ([]byte, error) {
+	gotHrp, data, err := bech32.Decode(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if gotHrp != hrp {
+		return nil, fmt.Errorf("unexpected key type %q", gotHrp)
+	}
+
+	key, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != curve25519.PointSize {
+		return nil, fmt.Errorf("invalid key length %d", len(key))
+	}
+	return key, nil
+}
@@ -0,0 +1,104 @@
+package mnemonic
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBundleRoundtrip(t *testing.T) {
+	plain := []byte("7b2254797065223a22626c73227d")
+	addr := "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy"
+
+	identity, recipient, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIdentity, _, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := SealBundle(addr, "hex-lotus", plain, []byte("one-time-pass"), []string{recipient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, plain) {
+		t.Fatal("bundle contains plaintext key")
+	}
+
+	b, err := ParseBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := OpenBundle(b, []byte("one-time-pass"), nil)
+	if err != nil || !bytes.Equal(out, plain) {
+		t.Fatalf("open with passphrase: %v", err)
+	}
+
+	out, err = OpenBundle(b, nil, []string{identity})
+	if err != nil || !bytes.Equal(out, plain) {
+		t.Fatalf("open with identity: %v", err)
+	}
+
+	if _, err := OpenBundle(b, []byte("wrong-pass"), []string{otherIdentity}); err != ErrNoMatchingKey {
+		t.Fatalf("expected ErrNoMatchingKey, got %v", err)
+	}
+
+	// 修改文件头中的任何一项都无法解密
+	tamper := []struct {
+		name string
+		fn   func(b *Bundle)
+	}{
+		{"address", func(b *Bundle) { b.Address = "f1aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" }},
+		{"format", func(b *Bundle) { b.Format = "raw-secp256k1" }},
+		{"cipher", func(b *Bundle) { b.Cipher = "none" }},
+		{"passphrase", func(b *Bundle) { b.Passphrase = nil }},
+		{"recipients", func(b *Bundle) { b.Recipients = append(b.Recipients, b.Recipients[0]) }},
+	}
+	for _, tc := range tamper {
+		b, err := ParseBundle(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.fn(b)
+		if _, err := OpenBundle(b, nil, []string{identity}); err == nil {
+			t.Errorf("expected error for tampered %s", tc.name)
+		}
+	}
+}
+
+func TestBundleScryptLimits(t *testing.T) {
+	data, err := SealBundle("f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy", "hex-lotus", []byte("key"), []byte("one-time-pass"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		n, r, p int
+		ok      bool
+	}{
+		{scryptN, scryptR, scryptP, true},
+		{maxScryptN * 2, scryptR, scryptP, false},
+		{1 << 30, scryptR, scryptP, false},
+		{scryptN, maxScryptR + 1, scryptP, false},
+		{scryptN, scryptR, maxScryptP + 1, false},
+		{scryptN, scryptR, 1 << 20, false},
+		{0, scryptR, scryptP, false},
+		{scryptN, 0, scryptP, false},
+		{scryptN, scryptR, 0, false},
+	}
+
+	for _, tc := range tests {
+		b, err := ParseBundle(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Passphrase.N, b.Passphrase.R, b.Passphrase.P = tc.n, tc.r, tc.p
+
+		_, err = OpenBundle(b, []byte("one-time-pass"), nil)
+		if (err == nil) != tc.ok {
+			t.Errorf("N=%d r=%d p=%d: ok=%v, err=%v", tc.n, tc.r, tc.p, tc.ok, err)
+		}
+	}
+}
@@ -0,0 +1,806 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/multisig"
+	"github.com/filecoin-project/lotus/chain/consensus/filcns"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	init2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/init"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var msigCmd = &cli.Command{
+	Name:  "msig",
+	Usage: "多签钱包管理，每个签名人在自己的机器上使用本钱包签名",
+	Subcommands: []*cli.Command{
+		msigCreateCmd,
+		msigProposeCmd,
+		msigApproveCmd,
+		msigCancelCmd,
+		msigInspectCmd,
+	},
+}
+
+// 多签发送命令共用的参数
+var msigSendFlags = append([]cli.Flag{
+	&cli.StringFlag{
+		Name:     "from",
+		Usage:    "签名人地址，必须是本钱包中的地址",
+		Required: true,
+	},
+	yesFlag,
+	waitFlag,
+	confidenceFlag,
+}, msgSendFlags...)
+
+var msigCreateCmd = &cli.Command{
+	Name:  "create",
+	Usage: "创建多签钱包",
+	Flags: append([]cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "signer",
+			Usage:    "签名人地址，可指定多个",
+			Required: true,
+		},
+		&cli.Uint64Flag{
+			Name:  "threshold",
+			Usage: "需要的签名数，默认为所有签名人",
+		},
+		&cli.StringFlag{
+			Name:  "value",
+			Usage: "创建时转入的金额(FIL)",
+			Value: "0",
+		},
+		&cli.Int64Flag{
+			Name:  "vesting-start",
+			Usage: "线性释放的起始高度，默认为当前链高度",
+		},
+		&cli.Int64Flag{
+			Name:  "vesting-duration",
+			Usage: "线性释放的高度数，0表示不锁定",
+		},
+	}, msigSendFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		var signers []address.Address
+		for _, s := range cctx.StringSlice("signer") {
+			a, err := address.NewFromString(s)
+			if err != nil {
+				fmt.Printf("解析签名人地址(%s)失败: %v\n", s, err)
+				return err
+			}
+			signers = append(signers, a)
+		}
+
+		threshold := cctx.Uint64("threshold")
+		if threshold == 0 {
+			threshold = uint64(len(signers))
+		}
+		if threshold > uint64(len(signers)) {
+			fmt.Println("签名数不能超过签名人数量")
+			return xerrors.Errorf("threshold %d exceeds %d signers", threshold, len(signers))
+		}
+
+		value, err := types.ParseFIL(cctx.String("value"))
+		if err != nil {
+			fmt.Printf("解析金额失败: %v\n", err)
+			return err
+		}
+
+		duration := abi.ChainEpoch(cctx.Int64("vesting-duration"))
+		if duration < 0 {
+			fmt.Println("线性释放的高度数不能为负数")
+			return xerrors.Errorf("negative vesting duration %d", duration)
+		}
+
+		return msigSend(cctx, func(ctx context.Context, api v0api.FullNode, mb multisig.MessageBuilder) (*types.Message, error) {
+			// 起始高度为0时锁定的金额在上链时已经全部释放
+			start := abi.ChainEpoch(cctx.Int64("vesting-start"))
+			if duration > 0 && !cctx.IsSet("vesting-start") {
+				head, err := api.ChainHead(ctx)
+				if err != nil {
+					return nil, xerrors.Errorf("getting chain head: %w", err)
+				}
+				start = head.Height()
+				fmt.Printf("线性释放起始高度: %d\n", start)
+			}
+			return mb.Create(signers, threshold, start, duration, abi.TokenAmount(value))
+		}, signers[len(signers)-1], func(wait *lapi.MsgLookup) {
+			var ret init2.ExecReturn
+			if err := ret.UnmarshalCBOR(bytes.NewReader(wait.Receipt.Return)); err != nil {
+				fmt.Println("解析返回值失败:", err)
+				return
+			}
+			fmt.Printf("多签钱包已创建: %s ID: %s\n", ret.RobustAddress, ret.IDAddress)
+		})
+	},
+}
+
+var msigProposeCmd = &cli.Command{
+	Name:  "propose",
+	Usage: "发起多签交易，可以是转账或调用actor方法",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "msig",
+			Usage:    "多签钱包地址",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "交易的接收地址",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "value",
+			Usage: "转账金额(FIL)",
+			Value: "0",
+		},
+		&cli.StringFlag{
+			Name:  "method",
+			Usage: "方法名称或编号，默认为转账",
+			Value: "0",
+		},
+		&cli.StringFlag{
+			Name:  "params-json",
+			Usage: "JSON格式的方法参数",
+		},
+	}, msigSendFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		msig, err := address.NewFromString(cctx.String("msig"))
+		if err != nil {
+			fmt.Printf("解析多签钱包地址失败: %v\n", err)
+			return err
+		}
+
+		to, err := address.NewFromString(cctx.String("to"))
+		if err != nil {
+			fmt.Printf("解析接收地址失败: %v\n", err)
+			return err
+		}
+
+		value, err := types.ParseFIL(cctx.String("value"))
+		if err != nil {
+			fmt.Printf("解析金额失败: %v\n", err)
+			return err
+		}
+
+		return msigSend(cctx, func(ctx context.Context, api v0api.FullNode, mb multisig.MessageBuilder) (*types.Message, error) {
+			method, params, err := resolveMethodParams(ctx, api, to, cctx.String("method"), cctx.String("params-json"))
+			if err != nil {
+				return nil, err
+			}
+			return mb.Propose(msig, to, abi.TokenAmount(value), method, params)
+		}, to, printProposeReturn)
+	},
+}
+
+var msigApproveCmd = &cli.Command{
+	Name:      "approve",
+	Usage:     "批准多签交易，签名前显示解码后的交易内容",
+	ArgsUsage: "<txid>",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "msig",
+			Usage:    "多签钱包地址",
+			Required: true,
+		},
+	}, msigSendFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		return msigTxnAction(cctx, false)
+	},
+}
+
+var msigCancelCmd = &cli.Command{
+	Name:      "cancel",
+	Usage:     "取消自己发起的多签交易",
+	ArgsUsage: "<txid>",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "msig",
+			Usage:    "多签钱包地址",
+			Required: true,
+		},
+	}, msigSendFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		return msigTxnAction(cctx, true)
+	},
+}
+
+func msigTxnAction(cctx *cli.Context, cancel bool) error {
+	if !passwdValid {
+		fmt.Println("密码错误.")
+		return fmt.Errorf("密码错误")
+	}
+
+	if cctx.NArg() != 1 {
+		fmt.Println("必须指定交易ID")
+		return fmt.Errorf("must pass txid")
+	}
+
+	txid, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+	if err != nil {
+		fmt.Println("解析交易ID失败,", err)
+		return err
+	}
+
+	msig, err := address.NewFromString(cctx.String("msig"))
+	if err != nil {
+		fmt.Printf("解析多签钱包地址失败: %v\n", err)
+		return err
+	}
+
+	return msigSend(cctx, func(ctx context.Context, api v0api.FullNode, mb multisig.MessageBuilder) (*types.Message, error) {
+		txn, err := msigPendingTxn(ctx, api, msig, txid)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Printf("多签交易 %d:\n", txid)
+		printMsigTxn(ctx, api, txn)
+
+		// 带上交易内容的哈希，防止批准或取消与显示内容不同的交易
+		hash := &multisig.ProposalHashData{
+			Requester: txn.Approved[0],
+			To:        txn.To,
+			Value:     txn.Value,
+			Method:    txn.Method,
+			Params:    txn.Params,
+		}
+		if cancel {
+			return mb.Cancel(msig, txid, hash)
+		}
+		return mb.Approve(msig, txid, hash)
+	}, address.Undef, nil)
+}
+
+var msigInspectCmd = &cli.Command{
+	Name:      "inspect",
+	Usage:     "查看多签钱包的余额、签名人、锁定金额和待批准的交易",
+	ArgsUsage: "<msig address>",
+	Before: func(context *cli.Context) error {
+		return _initDb()
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定多签钱包地址")
+			return fmt.Errorf("must pass msig address")
+		}
+
+		msig, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			fmt.Printf("解析多签钱包地址失败: %v\n", err)
+			return err
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		act, mstate, err := loadMsig(ctx, api, msig)
+		if err != nil {
+			fmt.Printf("读取多签钱包失败，err:%v\n", err)
+			return err
+		}
+
+		locked, err := mstate.LockedBalance(head.Height())
+		if err != nil {
+			return err
+		}
+		threshold, err := mstate.Threshold()
+		if err != nil {
+			return err
+		}
+		signers, err := mstate.Signers()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("地址:    ", describeAddress(ctx, api, msig))
+		fmt.Println("余额:    ", types.FIL(act.Balance))
+		fmt.Println("可用余额:", types.FIL(big.Sub(act.Balance, locked)))
+
+		start, err := mstate.StartEpoch()
+		if err != nil {
+			return err
+		}
+		duration, err := mstate.UnlockDuration()
+		if err != nil {
+			return err
+		}
+		if duration > 0 {
+			initial, err := mstate.InitialBalance()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("线性释放: 初始锁定 %s，起始高度 %d，释放高度数 %d，当前锁定 %s\n",
+				types.FIL(initial), start, duration, types.FIL(locked))
+		}
+
+		fmt.Printf("签名数:   %d / %d\n", threshold, len(signers))
+		for _, s := range signers {
+			fmt.Println("  签名人:", describeAddress(ctx, api, s))
+		}
+
+		type pending struct {
+			id  int64
+			txn multisig.Transaction
+		}
+		var txns []pending
+		if err := mstate.ForEachPendingTxn(func(id int64, txn multisig.Transaction) error {
+			txns = append(txns, pending{id, txn})
+			return nil
+		}); err != nil {
+			return err
+		}
+		sort.Slice(txns, func(i, j int) bool { return txns[i].id < txns[j].id })
+
+		if len(txns) == 0 {
+			fmt.Println("没有待批准的交易")
+			return nil
+		}
+
+		fmt.Println("待批准的交易:")
+		tw := tablewriter.New(
+			tablewriter.Col("ID"),
+			tablewriter.Col("To"),
+			tablewriter.Col("Value"),
+			tablewriter.Col("Method"),
+			tablewriter.Col("Approved"),
+			tablewriter.NewLineCol("Params"))
+		for _, p := range txns {
+			name, params := describeCall(ctx, api, p.txn.To, p.txn.Method, p.txn.Params)
+			var approved []string
+			for _, a := range p.txn.Approved {
+				approved = append(approved, a.String())
+			}
+			tw.Write(map[string]interface{}{
+				"ID":       p.id,
+				"To":       p.txn.To,
+				"Value":    types.FIL(p.txn.Value),
+				"Method":   name,
+				"Approved": fmt.Sprintf("%d/%d %s", len(p.txn.Approved), threshold, strings.Join(approved, ",")),
+				"Params":   params,
+			})
+		}
+		return tw.Flush(os.Stdout)
+	},
+}
+
+// 构造多签消息后走正常的评估、签名、推送流程。--wait时用onReturn解析返回值
+func msigSend(cctx *cli.Context, build func(context.Context, v0api.FullNode, multisig.MessageBuilder) (*types.Message, error), confirm address.Address, onReturn func(*lapi.MsgLookup)) error {
+	from, err := address.NewFromString(cctx.String("from"))
+	if err != nil {
+		fmt.Printf("解析签名人地址失败: %v\n", err)
+		return err
+	}
+
+	api, closer, err := lcli.GetFullNodeAPI(cctx)
+	if err != nil {
+		fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+		return err
+	}
+	defer closer()
+
+	ctx := lcli.ReqContext(cctx)
+
+	mb, err := msigMessageBuilder(ctx, api, from)
+	if err != nil {
+		fmt.Printf("读取网络版本失败，err:%v\n", err)
+		return err
+	}
+
+	msg, err := build(ctx, api, mb)
+	if err != nil {
+		fmt.Printf("构造多签消息失败，err:%v\n", err)
+		return err
+	}
+
+	if msg.Nonce, err = nextNonce(ctx, api, from); err != nil {
+		fmt.Printf("获取nonce失败，err:%v\n", err)
+		return err
+	}
+
+	c, err := sendMessage(cctx, api, msg, confirm)
+	if err != nil {
+		return err
+	}
+	if !c.Defined() {
+		return nil
+	}
+
+	fmt.Println("Message CID:", c)
+
+	if !cctx.Bool("wait") {
+		return nil
+	}
+
+	wait, err := waitMessage(cctx, api, c)
+	if err != nil {
+		return err
+	}
+	if onReturn != nil {
+		onReturn(wait)
+	}
+	return nil
+}
+
+func msigMessageBuilder(ctx context.Context, api v0api.FullNode, from address.Address) (multisig.MessageBuilder, error) {
+	nv, err := api.StateNetworkVersion(ctx, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+
+	av, err := actors.VersionForNetwork(nv)
+	if err != nil {
+		return nil, err
+	}
+	return multisig.Message(av, from), nil
+}
+
+func loadMsig(ctx context.Context, api v0api.FullNode, msig address.Address) (*types.Actor, multisig.State, error) {
+	act, err := api.StateGetActor(ctx, msig, types.EmptyTSK)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store := adt.WrapStore(ctx, cbor.NewCborStore(blockstore.NewAPIBlockstore(api)))
+	mstate, err := multisig.Load(store, act)
+	if err != nil {
+		return nil, nil, err
+	}
+	return act, mstate, nil
+}
+
+func msigPendingTxn(ctx context.Context, api v0api.FullNode, msig address.Address, txid uint64) (*multisig.Transaction, error) {
+	_, mstate, err := loadMsig(ctx, api, msig)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *multisig.Transaction
+	if err := mstate.ForEachPendingTxn(func(id int64, txn multisig.Transaction) error {
+		if uint64(id) == txid {
+			found = &txn
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, xerrors.Errorf("no pending transaction %d in %s", txid, msig)
+	}
+	return found, nil
+}
+
+func printMsigTxn(ctx context.Context, api v0api.FullNode, txn *multisig.Transaction) {
+	name, params := describeCall(ctx, api, txn.To, txn.Method, txn.Params)
+	fmt.Println("  接收方:", describeAddress(ctx, api, txn.To))
+	fmt.Println("  金额:  ", types.FIL(txn.Value))
+	fmt.Printf("  方法:   %s (%d)\n", name, txn.Method)
+	if params != "" {
+		fmt.Println("  参数:")
+		for _, l := range strings.Split(params, "\n") {
+			fmt.Println("    " + l)
+		}
+	}
+	for _, a := range txn.Approved {
+		fmt.Println("  已批准:", describeAddress(ctx, api, a))
+	}
+}
+
+// 返回方法名称和解码后的参数JSON，无法解码时返回参数hex
+func describeCall(ctx context.Context, api v0api.FullNode, to address.Address, method abi.MethodNum, params []byte) (string, string) {
+	metas := messageMethods(ctx, api, &types.Message{To: to, Method: method})
+
+	name := strconv.FormatUint(uint64(method), 10)
+	if len(metas) > 0 {
+		name = metas[0].Name
+	}
+
+	if len(params) == 0 {
+		return name, ""
+	}
+	if p, _ := decodeParams(metas, params); p != "" {
+		return name, p
+	}
+	return name, fmt.Sprintf("%x", params)
+}
+
+// 查找接收方actor的方法编号并编码JSON参数，方法为0（转账）时不需要参数
+func resolveMethodParams(ctx context.Context, api v0api.FullNode, to address.Address, method, paramsJSON string) (abi.MethodNum, []byte, error) {
+	if method == "0" && paramsJSON == "" {
+		return 0, nil, nil
+	}
+
+	act, err := api.StateGetActor(ctx, to, types.EmptyTSK)
+	if err != nil {
+		fmt.Printf("读取接收地址actor失败，err:%v\n", err)
+		return 0, nil, err
+	}
+
+	methods := filcns.NewActorRegistry().Methods[act.Code]
+	num, meta, err := lookupMethod(methods, method)
+	if err != nil {
+		fmt.Printf("actor(%s)没有方法%s，可用的方法: %s\n", to, method, methodNames(methods))
+		return 0, nil, err
+	}
+
+	params, err := encodeJSONParams(meta, paramsJSON)
+	if err != nil {
+		return 0, nil, err
+	}
+	return num, params, nil
+}
+
+func printProposeReturn(wait *lapi.MsgLookup) {
+	var ret multisig.ProposeReturn
+	if err := ret.UnmarshalCBOR(bytes.NewReader(wait.Receipt.Return)); err != nil {
+		fmt.Println("解析返回值失败:", err)
+		return
+	}
+
+	fmt.Println("多签交易ID:", ret.TxnID)
+	if ret.Applied {
+		fmt.Printf("交易已执行，ExitCode: %d\n", ret.Code)
+	} else {
+		fmt.Println("等待其他签名人批准: msig approve --msig <msig>", ret.TxnID)
+	}
+}
+
+var msigSignerFlag = &cli.StringFlag{
+	Name:  "msig-signer",
+	Usage: "地址为多签钱包时用于签名的签名人，默认使用本钱包中的第一个签名人",
+}
+
+// owner等地址为多签钱包时使用的参数
+var msigOwnerFlags = []cli.Flag{
+	msigSignerFlag,
+	&cli.Uint64Flag{
+		Name:  "approve",
+		Usage: "地址为多签钱包时，批准其他签名人发起的交易ID",
+	},
+}
+
+// 以actor地址的名义发送消息。actor为多签钱包时由本钱包中的签名人发起Propose，否则用actor的公钥地址签名。
+// confirm为签名前需要输入确认的地址，返回的bool表示消息是否包装为多签交易
+func sendAs(cctx *cli.Context, api v0api.FullNode, actor address.Address, inner *types.Message, confirm address.Address) (cid.Cid, bool, error) {
+	ctx := lcli.ReqContext(cctx)
+
+	act, err := api.StateGetActor(ctx, actor, types.EmptyTSK)
+	if err != nil {
+		fmt.Printf("读取地址(%s)链上状态失败，err:%v\n", actor, err)
+		return cid.Undef, false, err
+	}
+
+	msg := inner
+	wrapped := builtin.IsMultisigActor(act.Code)
+	if wrapped {
+		signer, err := msigSigner(cctx, api, actor)
+		if err != nil {
+			return cid.Undef, false, err
+		}
+
+		mb, err := msigMessageBuilder(ctx, api, signer)
+		if err != nil {
+			fmt.Printf("读取网络版本失败，err:%v\n", err)
+			return cid.Undef, false, err
+		}
+
+		if msg, err = mb.Propose(actor, inner.To, inner.Value, inner.Method, inner.Params); err != nil {
+			fmt.Printf("构造多签消息失败，err:%v\n", err)
+			return cid.Undef, false, err
+		}
+
+		fmt.Printf("地址 %s 是多签钱包，由签名人 %s 发起多签交易:\n", actor, signer)
+		printMsigTxn(ctx, api, &multisig.Transaction{
+			To:     inner.To,
+			Value:  inner.Value,
+			Method: inner.Method,
+			Params: inner.Params,
+		})
+	} else {
+		if msg.From, err = api.StateAccountKey(ctx, actor, types.EmptyTSK); err != nil {
+			fmt.Printf("%s: error getting account key: %s\n", actor, err)
+			return cid.Undef, false, err
+		}
+	}
+
+	if msg.Nonce, err = nextNonce(ctx, api, msg.From); err != nil {
+		fmt.Printf("获取发送地址的nonce失败，err:%v\n", err)
+		return cid.Undef, false, err
+	}
+
+	c, err := sendMessage(cctx, api, msg, confirm)
+	return c, wrapped, err
+}
+
+// 批准多签钱包中发给to的交易，签名前显示解码后的交易内容并输入confirm地址确认
+func approveAs(cctx *cli.Context, api v0api.FullNode, msig, to, confirm address.Address) (cid.Cid, error) {
+	ctx := lcli.ReqContext(cctx)
+	txid := cctx.Uint64("approve")
+
+	act, err := api.StateGetActor(ctx, msig, types.EmptyTSK)
+	if err != nil {
+		fmt.Printf("读取地址(%s)链上状态失败，err:%v\n", msig, err)
+		return cid.Undef, err
+	}
+	if !builtin.IsMultisigActor(act.Code) {
+		fmt.Printf("地址 %s 不是多签钱包，不能使用 --approve\n", msig)
+		return cid.Undef, xerrors.Errorf("%s is not a multisig", msig)
+	}
+
+	txn, err := msigPendingTxn(ctx, api, msig, txid)
+	if err != nil {
+		fmt.Printf("读取多签交易失败，err:%v\n", err)
+		return cid.Undef, err
+	}
+	if txn.To != to {
+		fmt.Printf("多签交易 %d 的接收方是 %s，不是 %s\n", txid, txn.To, to)
+		return cid.Undef, xerrors.Errorf("transaction %d is sent to %s, not %s", txid, txn.To, to)
+	}
+
+	fmt.Printf("多签交易 %d:\n", txid)
+	printMsigTxn(ctx, api, txn)
+
+	signer, err := msigSigner(cctx, api, msig)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	mb, err := msigMessageBuilder(ctx, api, signer)
+	if err != nil {
+		fmt.Printf("读取网络版本失败，err:%v\n", err)
+		return cid.Undef, err
+	}
+
+	msg, err := mb.Approve(msig, txid, &multisig.ProposalHashData{
+		Requester: txn.Approved[0],
+		To:        txn.To,
+		Value:     txn.Value,
+		Method:    txn.Method,
+		Params:    txn.Params,
+	})
+	if err != nil {
+		fmt.Printf("构造多签消息失败，err:%v\n", err)
+		return cid.Undef, err
+	}
+
+	if msg.Nonce, err = nextNonce(ctx, api, signer); err != nil {
+		fmt.Printf("获取签名人的nonce失败，err:%v\n", err)
+		return cid.Undef, err
+	}
+
+	return sendMessage(cctx, api, msg, confirm)
+}
+
+// 选择多签钱包的签名人：--msig-signer指定的地址，或本钱包中的第一个签名人
+func msigSigner(cctx *cli.Context, api v0api.FullNode, msig address.Address) (address.Address, error) {
+	ctx := lcli.ReqContext(cctx)
+
+	_, mstate, err := loadMsig(ctx, api, msig)
+	if err != nil {
+		fmt.Printf("读取多签钱包失败，err:%v\n", err)
+		return address.Undef, err
+	}
+
+	signers, err := mstate.Signers()
+	if err != nil {
+		return address.Undef, err
+	}
+
+	var want address.Address
+	if s := cctx.String("msig-signer"); s != "" {
+		a, err := address.NewFromString(s)
+		if err != nil {
+			fmt.Printf("解析签名人地址失败: %v\n", err)
+			return address.Undef, err
+		}
+		if want, err = api.StateLookupID(ctx, a, types.EmptyTSK); err != nil {
+			fmt.Printf("读取签名人地址链上状态失败: %v\n", err)
+			return address.Undef, err
+		}
+	}
+
+	for _, s := range signers {
+		id, err := api.StateLookupID(ctx, s, types.EmptyTSK)
+		if err != nil {
+			continue
+		}
+		if want != address.Undef && id != want {
+			continue
+		}
+
+		key, err := api.StateAccountKey(ctx, s, types.EmptyTSK)
+		if err != nil {
+			continue
+		}
+		if _, err := getAddressInfo(key.String()); err == nil {
+			return key, nil
+		}
+	}
+
+	if want != address.Undef {
+		fmt.Printf("%s 不是多签钱包 %s 的签名人，或者私钥不在本钱包中\n", cctx.String("msig-signer"), msig)
+	} else {
+		fmt.Printf("本钱包中没有多签钱包 %s 的签名人\n", msig)
+	}
+	return address.Undef, xerrors.Errorf("no local signer for %s", msig)
+}
+
+// 输出多签交易的执行结果，返回内部调用是否已成功执行
+func msigApplied(cctx *cli.Context, wait *lapi.MsgLookup) bool {
+	var applied bool
+	var code exitcode.ExitCode
+	if cctx.IsSet("approve") {
+		var ret multisig.ApproveReturn
+		if err := ret.UnmarshalCBOR(bytes.NewReader(wait.Receipt.Return)); err != nil {
+			fmt.Println("解析返回值失败:", err)
+			return false
+		}
+		applied, code = ret.Applied, ret.Code
+		if !applied {
+			fmt.Println("已批准，等待其他签名人批准")
+		}
+	} else {
+		var ret multisig.ProposeReturn
+		if err := ret.UnmarshalCBOR(bytes.NewReader(wait.Receipt.Return)); err != nil {
+			fmt.Println("解析返回值失败:", err)
+			return false
+		}
+		applied, code = ret.Applied, ret.Code
+		if !applied {
+			fmt.Printf("多签交易ID: %d，等待其他签名人使用 --approve %d 批准\n", ret.TxnID, ret.TxnID)
+		}
+	}
+
+	if applied && code != exitcode.Ok {
+		fmt.Printf("多签交易已执行，但内部调用失败，ExitCode: %d\n", code)
+		return false
+	}
+	return applied
+}
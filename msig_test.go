@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/types"
+	init2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/init"
+	multisig2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/multisig"
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"testing"
+)
+
+func TestMsigCreate(t *testing.T) {
+	from := setupTestWallet(t)
+	other := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+
+	node := newTestNode()
+	node.addAccount(from, testAddress(t, "f01000"), types.FromFil(100))
+
+	// 确认最后一个签名人，而不是初始化actor的地址f01
+	withStdin(t, other.String()[len(other.String())-4:]+"\n")
+	if err := runTestCommand(node, msigCreateCmd,
+		"--from", from.String(), "--signer", from.String(), "--signer", other.String(), "--threshold", "2", "--value", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runTestCommand(node, msigCreateCmd,
+		"--yes", "--from", from.String(), "--signer", from.String(), "--signer", other.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(node.pushed) != 2 {
+		t.Fatalf("expected 2 pushed messages, got %d", len(node.pushed))
+	}
+	for i, sm := range node.pushed {
+		if sm.Message.To != builtin7.InitActorAddr || sm.Message.Method != builtin7.MethodsInit.Exec {
+			t.Errorf("message %d: unexpected call %s.%d", i, sm.Message.To, sm.Message.Method)
+		}
+		if sm.Message.From != from || sm.Message.Nonce != uint64(i) {
+			t.Errorf("message %d: from %s nonce %d", i, sm.Message.From, sm.Message.Nonce)
+		}
+	}
+	if !node.pushed[0].Message.Value.Equals(types.FromFil(1)) {
+		t.Errorf("unexpected value %s", types.FIL(node.pushed[0].Message.Value))
+	}
+
+	// 确认输入不正确时不签名
+	withStdin(t, "f01\n")
+	if err := runTestCommand(node, msigCreateCmd,
+		"--from", from.String(), "--signer", from.String()); err == nil {
+		t.Fatal("expected confirmation error")
+	}
+	if len(node.pushed) != 2 {
+		t.Fatalf("unconfirmed message was pushed")
+	}
+}
+
+func TestMsigCreateVesting(t *testing.T) {
+	from := setupTestWallet(t)
+
+	node := newTestNode()
+	node.addAccount(from, testAddress(t, "f01000"), types.FromFil(100))
+	node.heights = []abi.ChainEpoch{1500000}
+
+	tests := []struct {
+		args     []string
+		start    abi.ChainEpoch
+		duration abi.ChainEpoch
+	}{
+		{nil, 0, 0},
+		// 未指定起始高度时使用当前链高度
+		{[]string{"--vesting-duration", "10000"}, 1500000, 10000},
+		{[]string{"--vesting-start", "1600000", "--vesting-duration", "10000"}, 1600000, 10000},
+	}
+
+	for i, tc := range tests {
+		args := append([]string{"--yes", "--from", from.String(), "--signer", from.String(), "--value", "1"}, tc.args...)
+		if err := runTestCommand(node, msigCreateCmd, args...); err != nil {
+			t.Fatal(err)
+		}
+		if len(node.pushed) != i+1 {
+			t.Fatalf("%v: message not pushed", tc.args)
+		}
+
+		var exec init2.ExecParams
+		if err := exec.UnmarshalCBOR(bytes.NewReader(node.pushed[i].Message.Params)); err != nil {
+			t.Fatal(err)
+		}
+		var params multisig2.ConstructorParams
+		if err := params.UnmarshalCBOR(bytes.NewReader(exec.ConstructorParams)); err != nil {
+			t.Fatal(err)
+		}
+		if params.StartEpoch != tc.start || params.UnlockDuration != tc.duration {
+			t.Errorf("%v: start %d duration %d, want %d %d", tc.args, params.StartEpoch, params.UnlockDuration, tc.start, tc.duration)
+		}
+	}
+
+	if err := runTestCommand(node, msigCreateCmd, "--yes", "--from", from.String(), "--signer", from.String(), "--vesting-duration", "-1"); err == nil {
+		t.Fatal("expected negative duration to be rejected")
+	}
+}
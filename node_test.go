@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/network"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/api/v1api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const testMnemonic = "tag volcano eight thank tide danger coast health above argue embrace heavy"
+
+// 测试用的节点，只实现发送消息流程用到的方法
+type testNode struct {
+	v1api.FullNode
+
+	actors map[address.Address]*types.Actor
+	ids    map[address.Address]address.Address
+	keys   map[address.Address]address.Address
+	market map[address.Address]lapi.MarketBalance
+	miners map[address.Address]*miner.MinerInfo
+	// ChainHead依次返回的高度，最后一个高度保持不变
+	heights []abi.ChainEpoch
+	genesis uint64
+
+	estimated []*lapi.MessageSendSpec
+	pushed    []*types.SignedMessage
+	pushErr   error
+	called    []*types.Message
+	// 消息推送后调用，用于模拟消息执行后的链上状态
+	onPush func(*types.SignedMessage)
+}
+
+func newTestNode() *testNode {
+	return &testNode{
+		actors: map[address.Address]*types.Actor{},
+		ids:    map[address.Address]address.Address{},
+		keys:   map[address.Address]address.Address{},
+		market: map[address.Address]lapi.MarketBalance{},
+		miners: map[address.Address]*miner.MinerInfo{},
+	}
+}
+
+// 添加账户地址及其ID地址
+func (n *testNode) addAccount(key, id address.Address, balance abi.TokenAmount) {
+	n.ids[key] = id
+	n.keys[id] = key
+	n.actors[key] = &types.Actor{Balance: balance}
+	n.actors[id] = n.actors[key]
+}
+
+func (n *testNode) GasEstimateMessageGas(ctx context.Context, msg *types.Message, spec *lapi.MessageSendSpec, tsk types.TipSetKey) (*types.Message, error) {
+	n.estimated = append(n.estimated, spec)
+
+	out := *msg
+	if out.GasLimit == 0 {
+		out.GasLimit = 1000000
+	}
+	if out.GasFeeCap.Nil() || out.GasFeeCap.IsZero() {
+		out.GasFeeCap = abi.NewTokenAmount(100000)
+	}
+	if out.GasPremium.Nil() || out.GasPremium.IsZero() {
+		out.GasPremium = abi.NewTokenAmount(1000)
+	}
+	return &out, nil
+}
+
+func (n *testNode) StateLookupID(ctx context.Context, a address.Address, tsk types.TipSetKey) (address.Address, error) {
+	if a.Protocol() == address.ID {
+		return a, nil
+	}
+	if id, ok := n.ids[a]; ok {
+		return id, nil
+	}
+	return address.Undef, xerrors.Errorf("resolution lookup failed (%s): actor not found", a)
+}
+
+func (n *testNode) StateAccountKey(ctx context.Context, a address.Address, tsk types.TipSetKey) (address.Address, error) {
+	if a.Protocol() == address.SECP256K1 || a.Protocol() == address.BLS {
+		return a, nil
+	}
+	if k, ok := n.keys[a]; ok {
+		return k, nil
+	}
+	return address.Undef, xerrors.Errorf("%s is not an account actor", a)
+}
+
+func (n *testNode) StateGetActor(ctx context.Context, a address.Address, tsk types.TipSetKey) (*types.Actor, error) {
+	if act, ok := n.actors[a]; ok {
+		return act, nil
+	}
+	return nil, xerrors.Errorf("actor not found")
+}
+
+func (n *testNode) StateNetworkVersion(ctx context.Context, tsk types.TipSetKey) (network.Version, error) {
+	return network.Version15, nil
+}
+
+func (n *testNode) WalletBalance(ctx context.Context, a address.Address) (types.BigInt, error) {
+	if act, ok := n.actors[a]; ok {
+		return act.Balance, nil
+	}
+	return big.Zero(), nil
+}
+
+func (n *testNode) StateMarketBalance(ctx context.Context, a address.Address, tsk types.TipSetKey) (lapi.MarketBalance, error) {
+	if id, ok := n.ids[a]; ok {
+		a = id
+	}
+	return n.market[a], nil
+}
+
+func (n *testNode) StateNetworkName(ctx context.Context) (dtypes.NetworkName, error) {
+	return "testnet", nil
+}
+
+func (n *testNode) StateCall(ctx context.Context, msg *types.Message, tsk types.TipSetKey) (*lapi.InvocResult, error) {
+	n.called = append(n.called, msg)
+	return &lapi.InvocResult{
+		Msg:            msg,
+		MsgRct:         &types.MessageReceipt{},
+		ExecutionTrace: types.ExecutionTrace{Msg: msg, MsgRct: &types.MessageReceipt{}},
+	}, nil
+}
+
+func (n *testNode) MpoolGetNonce(ctx context.Context, a address.Address) (uint64, error) {
+	var nonce uint64
+	if act, ok := n.actors[a]; ok {
+		nonce = act.Nonce
+	}
+	for _, sm := range n.pushed {
+		if (sm.Message.From == a || n.ids[sm.Message.From] == a) && sm.Message.Nonce >= nonce {
+			nonce = sm.Message.Nonce + 1
+		}
+	}
+	return nonce, nil
+}
+
+func (n *testNode) MpoolPending(ctx context.Context, tsk types.TipSetKey) ([]*types.SignedMessage, error) {
+	return n.pushed, nil
+}
+
+func (n *testNode) MpoolPush(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+	if n.pushErr != nil {
+		return cid.Undef, n.pushErr
+	}
+	n.pushed = append(n.pushed, sm)
+	if n.onPush != nil {
+		n.onPush(sm)
+	}
+	return sm.Cid(), nil
+}
+
+func (n *testNode) ChainHead(ctx context.Context) (*types.TipSet, error) {
+	var h abi.ChainEpoch
+	if len(n.heights) > 0 {
+		h = n.heights[0]
+		if len(n.heights) > 1 {
+			n.heights = n.heights[1:]
+		}
+	}
+	return testTipSet(h, n.genesis+uint64(h)*build.BlockDelaySecs)
+}
+
+func (n *testNode) ChainGetGenesis(ctx context.Context) (*types.TipSet, error) {
+	return testTipSet(0, n.genesis)
+}
+
+func testTipSet(h abi.ChainEpoch, timestamp uint64) (*types.TipSet, error) {
+	c, err := abi.CidBuilder.Sum([]byte("ff-wallet-test"))
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTipSet([]*types.BlockHeader{{
+		Miner:                 builtin.SystemActorAddr,
+		Height:                h,
+		Timestamp:             timestamp,
+		Ticket:                &types.Ticket{VRFProof: []byte{1}},
+		ParentStateRoot:       c,
+		ParentMessageReceipts: c,
+		Messages:              c,
+		ParentBaseFee:         big.Zero(),
+		ParentWeight:          big.Zero(),
+	}})
+}
+
+func (n *testNode) StateMinerInfo(ctx context.Context, a address.Address, tsk types.TipSetKey) (miner.MinerInfo, error) {
+	mi, ok := n.miners[a]
+	if !ok {
+		return miner.MinerInfo{}, xerrors.Errorf("actor not found")
+	}
+	return *mi, nil
+}
+
+func (n *testNode) StateWaitMsg(ctx context.Context, c cid.Cid, confidence uint64, limit abi.ChainEpoch, allowReplaced bool) (*lapi.MsgLookup, error) {
+	return &lapi.MsgLookup{Message: c, Receipt: types.MessageReceipt{}, TipSet: types.EmptyTSK}, nil
+}
+
+func (n *testNode) StateReplay(ctx context.Context, tsk types.TipSetKey, c cid.Cid) (*lapi.InvocResult, error) {
+	return nil, xerrors.New("not supported")
+}
+
+// 直接调用使用v0api的函数
+func v0Node(n *testNode) v0api.FullNode {
+	return &v0api.WrapperV1Full{FullNode: n}
+}
+
+// 使用临时数据库和测试助记词初始化钱包，返回一个派生的secp256k1地址
+func setupTestWallet(t *testing.T) address.Address {
+	dir, err := ioutil.TempDir("", "ff-wallet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) }) //nolint:errcheck
+
+	if localdb, err = db.Init(dir); err != nil {
+		t.Fatal(err)
+	}
+	localMnenoic = []byte(testMnemonic)
+	passwdValid = true
+
+	fais, err := generateAddresses(1, false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := address.NewFromString(fais[0].Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// 在测试节点上运行命令，跳过Before中的密码输入
+func runTestCommand(node *testNode, cmd *cli.Command, args ...string) error {
+	return runTestApp(node, nil, cmd, args...)
+}
+
+// 同runTestCommand，global为命令之前的全局参数，例如--dry-run
+func runTestApp(node *testNode, global []string, cmd *cli.Command, args ...string) error {
+	c := *cmd
+	c.Before = nil
+
+	app := &cli.App{
+		Name:     "firefly-wallet",
+		Flags:    []cli.Flag{dryRunFlag},
+		Commands: []*cli.Command{&c},
+		Metadata: map[string]interface{}{"testnode-full": node},
+	}
+	argv := append(append([]string{"firefly-wallet"}, global...), c.Name)
+	return app.Run(append(argv, args...))
+}
+
+// 使用flags解析args后调用fn，用于测试读取命令参数的函数
+func runTestFlags(node *testNode, flags []cli.Flag, fn cli.ActionFunc, args ...string) error {
+	return runTestCommand(node, &cli.Command{Name: "test", Flags: flags, Action: fn}, args...)
+}
+
+// 将标准输入替换为input，用于测试签名前的确认
+func withStdin(t *testing.T, input string) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	w.Close() //nolint:errcheck
+
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close() //nolint:errcheck
+	})
+}
+
+func testAddress(t *testing.T, s string) address.Address {
+	a, err := address.NewFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
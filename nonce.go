@@ -0,0 +1,453 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/filecoin-project/specs-actors/v5/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 本地记录的已分配nonce
+type issuedNonce struct {
+	Nonce uint64
+	Cid   cid.Cid
+}
+
+// 每个发送地址的nonce记录，保存在数据库中
+type nonceRecord struct {
+	// 本地分配的下一个nonce
+	Next   uint64
+	Issued []issuedNonce
+}
+
+// 发送地址的nonce状态
+type nonceState struct {
+	Key    string
+	Chain  uint64
+	Mpool  uint64
+	Record *nonceRecord
+	// 消息池中该地址的待上链消息
+	Pending map[uint64]cid.Cid
+}
+
+// 本地分配但节点消息池中不存在的nonce
+func (s *nonceState) missing() []uint64 {
+	var out []uint64
+	for n := s.Mpool; n < s.Record.Next; n++ {
+		if _, ok := s.Pending[n]; !ok {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// 消息池中卡住的nonce：有更大nonce的消息在等待，但该nonce没有消息
+func (s *nonceState) gaps() []uint64 {
+	var max uint64
+	for n := range s.Pending {
+		if n > max {
+			max = n
+		}
+	}
+
+	var out []uint64
+	for n := s.Chain; n < max; n++ {
+		if _, ok := s.Pending[n]; !ok {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// 同一账户可能以ID地址或公钥地址发送，统一使用公钥地址记录。新地址第一次收到转账后才分配ID，
+// 使用公钥地址可以保证分配ID前后使用同一条记录
+func nonceKey(ctx context.Context, api v0api.FullNode, addr address.Address) string {
+	if addr.Protocol() != address.ID {
+		return addr.String()
+	}
+	if key, err := api.StateAccountKey(ctx, addr, types.EmptyTSK); err == nil {
+		return key.String()
+	}
+	return addr.String()
+}
+
+// 读取nonce记录。之前版本使用ID地址记录，公钥地址没有记录时迁移ID地址的记录
+func loadNonceRecord(ctx context.Context, api v0api.FullNode, key string, addr address.Address) (*nonceRecord, error) {
+	rec, err := getNonceRecord(key)
+	if err != nil || rec.Next > 0 || len(rec.Issued) > 0 {
+		return rec, err
+	}
+
+	id, err := api.StateLookupID(ctx, addr, types.EmptyTSK)
+	if err != nil || id.String() == key {
+		return rec, nil
+	}
+
+	old, err := getNonceRecord(id.String())
+	if err != nil || (old.Next == 0 && len(old.Issued) == 0) {
+		return rec, err
+	}
+
+	data, err := json.Marshal(old)
+	if err != nil {
+		return nil, err
+	}
+	b := localdb.NewBatch()
+	b.Add(db.KeyNonce, key, data)
+	b.Del(db.KeyNonce, id.String())
+	if err := b.Commit(); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+func getNonceRecord(key string) (*nonceRecord, error) {
+	rec := new(nonceRecord)
+	data, err := localdb.Get(db.KeyNonce, key)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return rec, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, xerrors.Errorf("decoding nonce record %s: %w", key, err)
+	}
+	return rec, nil
+}
+
+func putNonceRecord(key string, rec *nonceRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return localdb.Add(db.KeyNonce, key, data)
+}
+
+// 读取链上nonce、消息池nonce和本地记录，并清理已上链的本地记录
+func loadNonceState(ctx context.Context, api v0api.FullNode, addr address.Address) (*nonceState, error) {
+	s := &nonceState{Key: addr.String(), Pending: map[uint64]cid.Cid{}}
+
+	// 消息池中的消息可能使用ID地址或公钥地址
+	from := map[address.Address]struct{}{addr: {}}
+	if id, err := api.StateLookupID(ctx, addr, types.EmptyTSK); err == nil {
+		from[id] = struct{}{}
+	}
+	if key, err := api.StateAccountKey(ctx, addr, types.EmptyTSK); err == nil {
+		s.Key = key.String()
+		from[key] = struct{}{}
+	}
+
+	act, err := api.StateGetActor(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		if !strings.Contains(err.Error(), "actor not found") {
+			return nil, xerrors.Errorf("getting actor: %w", err)
+		}
+	} else {
+		s.Chain = act.Nonce
+	}
+
+	s.Mpool, err = api.MpoolGetNonce(ctx, addr)
+	if err != nil {
+		fmt.Printf("读取获取消息池中的的nonce失败，使用链上nonce，err:%v\n", err)
+		s.Mpool = s.Chain
+	}
+
+	pending, err := api.MpoolPending(ctx, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting pending messages: %w", err)
+	}
+	for _, sm := range pending {
+		if _, ok := from[sm.Message.From]; ok {
+			s.Pending[sm.Message.Nonce] = sm.Cid()
+		}
+	}
+
+	s.Record, err = loadNonceRecord(ctx, api, s.Key, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var issued []issuedNonce
+	for _, in := range s.Record.Issued {
+		if in.Nonce >= s.Chain {
+			issued = append(issued, in)
+		}
+	}
+	if len(issued) != len(s.Record.Issued) {
+		s.Record.Issued = issued
+		if err := putNonceRecord(s.Key, s.Record); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// 获取发送地址的下一个nonce，取消息池nonce和本地已分配nonce中较大的一个
+func nextNonce(ctx context.Context, api v0api.FullNode, addr address.Address) (uint64, error) {
+	s, err := loadNonceState(ctx, api, addr)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.Record.Next <= s.Mpool {
+		return s.Mpool, nil
+	}
+
+	// 本地分配过但未推送（例如--unsigned-out写出的消息），或者消息已从消息池中丢失
+	if missing := s.missing(); len(missing) > 0 {
+		fmt.Printf("警告: 地址%s本地已分配的nonce %v 不在消息池中，新消息可能无法上链，可使用 nonce show/fix 检查\n", addr, missing)
+	}
+	return s.Record.Next, nil
+}
+
+// 记录已使用的nonce，消息推送或写出后调用
+func recordNonce(ctx context.Context, api v0api.FullNode, msg *types.Message, c cid.Cid) error {
+	key := nonceKey(ctx, api, msg.From)
+	rec, err := loadNonceRecord(ctx, api, key, msg.From)
+	if err != nil {
+		return err
+	}
+
+	if msg.Nonce >= rec.Next {
+		rec.Next = msg.Nonce + 1
+	}
+
+	issued := issuedNonce{Nonce: msg.Nonce, Cid: c}
+	replaced := false
+	for i, in := range rec.Issued {
+		if in.Nonce == msg.Nonce {
+			rec.Issued[i] = issued
+			replaced = true
+		}
+	}
+	if !replaced {
+		rec.Issued = append(rec.Issued, issued)
+	}
+
+	return putNonceRecord(key, rec)
+}
+
+var nonceCmd = &cli.Command{
+	Name:  "nonce",
+	Usage: "查看和修复发送地址的nonce",
+	Subcommands: []*cli.Command{
+		nonceShowCmd,
+		nonceFixCmd,
+	},
+}
+
+var nonceShowCmd = &cli.Command{
+	Name:      "show",
+	Usage:     "显示地址的链上nonce、消息池nonce、本地分配的nonce和待上链消息",
+	ArgsUsage: "[address]",
+	Before: func(context *cli.Context) error {
+		return _initDb()
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定一个钱包地址")
+			return fmt.Errorf("must pass one address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			fmt.Println("解析钱包地址失败,", err)
+			return err
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		s, err := loadNonceState(lcli.ReqContext(cctx), api, addr)
+		if err != nil {
+			fmt.Printf("读取nonce失败，err:%v\n", err)
+			return err
+		}
+
+		fmt.Println("链上nonce:", s.Chain)
+		fmt.Println("消息池nonce:", s.Mpool)
+		fmt.Println("本地下一个nonce:", s.Record.Next)
+
+		local := map[uint64]cid.Cid{}
+		for _, in := range s.Record.Issued {
+			local[in.Nonce] = in.Cid
+		}
+
+		var nonces []uint64
+		seen := map[uint64]struct{}{}
+		for n := range s.Pending {
+			nonces = append(nonces, n)
+			seen[n] = struct{}{}
+		}
+		for n := range local {
+			if _, ok := seen[n]; !ok {
+				nonces = append(nonces, n)
+			}
+		}
+		sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+
+		tw := tablewriter.New(
+			tablewriter.Col("Nonce"),
+			tablewriter.Col("Mpool"),
+			tablewriter.Col("Local"))
+		for _, n := range nonces {
+			row := map[string]interface{}{"Nonce": n}
+			if c, ok := s.Pending[n]; ok {
+				row["Mpool"] = c
+			} else {
+				row["Mpool"] = "-"
+			}
+			if c, ok := local[n]; ok {
+				row["Local"] = c
+			} else {
+				row["Local"] = "-"
+			}
+			tw.Write(row)
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+
+		if gaps := s.gaps(); len(gaps) > 0 {
+			fmt.Printf("消息池中缺少nonce %v，之后的消息无法上链，可使用 nonce fix --fill 填补\n", gaps)
+		}
+		if missing := s.missing(); len(missing) > 0 {
+			fmt.Printf("本地已分配的nonce %v 不在消息池中，如果这些消息不会再推送，可使用 nonce fix 重置\n", missing)
+		}
+		return nil
+	},
+}
+
+var nonceFixCmd = &cli.Command{
+	Name:      "fix",
+	Usage:     "将本地分配的nonce重置为消息池nonce；指定--fill时用0金额转给自己的消息填补消息池中缺少的nonce。指定--dry-run时只模拟执行填补消息并输出将要进行的修改",
+	ArgsUsage: "[address]",
+	Flags: append([]cli.Flag{
+		&cli.BoolFlag{
+			Name:  "fill",
+			Usage: "填补消息池中缺少的nonce",
+		},
+	}, gasFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定一个钱包地址")
+			return fmt.Errorf("must pass one address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			fmt.Println("解析钱包地址失败,", err)
+			return err
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		s, err := loadNonceState(ctx, api, addr)
+		if err != nil {
+			fmt.Printf("读取nonce失败，err:%v\n", err)
+			return err
+		}
+
+		if cctx.Bool("fill") {
+			for _, n := range s.gaps() {
+				msg, err := estimateMessageGas(cctx, api, &types.Message{
+					From:   addr,
+					To:     addr,
+					Value:  big.Zero(),
+					Method: builtin.MethodSend,
+					Nonce:  n,
+				})
+				if err != nil {
+					return err
+				}
+
+				if cctx.Bool("dry-run") {
+					fmt.Printf("nonce %d 的填补消息:\n", n)
+					if err := dryRunMessage(cctx, api, msg); err != nil {
+						return err
+					}
+					continue
+				}
+
+				sm, err := signChainMessage(ctx, api, msg)
+				if err != nil {
+					return err
+				}
+
+				c, err := pushMessage(cctx, api, sm)
+				if err != nil {
+					fmt.Printf("推送nonce %d 的填补消息失败\n", n)
+					return err
+				}
+				fmt.Printf("nonce %d 已填补: %s\n", n, c)
+			}
+
+			if cctx.Bool("dry-run") {
+				fmt.Println("模拟执行，未签名、未推送，未修改本地nonce记录")
+				return nil
+			}
+
+			if s, err = loadNonceState(ctx, api, addr); err != nil {
+				fmt.Printf("读取nonce失败，err:%v\n", err)
+				return err
+			}
+		}
+
+		if s.Record.Next > s.Mpool {
+			if cctx.Bool("dry-run") {
+				fmt.Printf("本地下一个nonce将由 %d 重置为 %d（模拟执行，未修改）\n", s.Record.Next, s.Mpool)
+				return nil
+			}
+
+			var issued []issuedNonce
+			for _, in := range s.Record.Issued {
+				if in.Nonce < s.Mpool {
+					issued = append(issued, in)
+				}
+			}
+			fmt.Printf("本地下一个nonce由 %d 重置为 %d\n", s.Record.Next, s.Mpool)
+			s.Record.Next = s.Mpool
+			s.Record.Issued = issued
+			if err := putNonceRecord(s.Key, s.Record); err != nil {
+				fmt.Printf("保存nonce记录失败，err:%v\n", err)
+				return err
+			}
+		}
+
+		fmt.Println("下一个nonce:", s.Mpool)
+		return nil
+	},
+}
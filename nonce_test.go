@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/specs-actors/v5/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"reflect"
+	"testing"
+)
+
+func TestNonceStateGapsMissing(t *testing.T) {
+	pending := func(ns ...uint64) map[uint64]cid.Cid {
+		m := map[uint64]cid.Cid{}
+		for _, n := range ns {
+			m[n] = cid.Undef
+		}
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		state   nonceState
+		gaps    []uint64
+		missing []uint64
+	}{
+		{"empty", nonceState{Record: &nonceRecord{}, Pending: pending()}, nil, nil},
+		{"contiguous", nonceState{Chain: 3, Mpool: 6, Record: &nonceRecord{Next: 6}, Pending: pending(3, 4, 5)}, nil, nil},
+		{"gap", nonceState{Chain: 3, Mpool: 4, Record: &nonceRecord{Next: 7}, Pending: pending(3, 5, 6)}, []uint64{4}, []uint64{4}},
+		{"gap at chain nonce", nonceState{Chain: 3, Mpool: 3, Record: &nonceRecord{Next: 6}, Pending: pending(5)}, []uint64{3, 4}, []uint64{3, 4}},
+		{"unsigned out", nonceState{Chain: 3, Mpool: 3, Record: &nonceRecord{Next: 5}, Pending: pending()}, nil, []uint64{3, 4}},
+		{"record behind mpool", nonceState{Chain: 3, Mpool: 5, Record: &nonceRecord{Next: 4}, Pending: pending(3, 4)}, nil, nil},
+	}
+
+	for _, tc := range tests {
+		if got := tc.state.gaps(); !reflect.DeepEqual(got, tc.gaps) {
+			t.Errorf("%s: gaps %v, want %v", tc.name, got, tc.gaps)
+		}
+		if got := tc.state.missing(); !reflect.DeepEqual(got, tc.missing) {
+			t.Errorf("%s: missing %v, want %v", tc.name, got, tc.missing)
+		}
+	}
+}
+
+func TestNonceRecordKey(t *testing.T) {
+	from := setupTestWallet(t)
+	id := testAddress(t, "f01000")
+	node := newTestNode()
+	ctx := context.Background()
+
+	// 地址还没有ID时分配的nonce
+	if err := recordNonce(ctx, v0Node(node), &types.Message{From: from, Nonce: 0}, cid.Undef); err != nil {
+		t.Fatal(err)
+	}
+
+	// 分配ID后，以ID地址或公钥地址读取都是同一条记录
+	node.addAccount(from, id, types.FromFil(1))
+	for _, a := range []address.Address{from, id} {
+		s, err := loadNonceState(ctx, v0Node(node), a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Key != from.String() || s.Record.Next != 1 {
+			t.Errorf("%s: key %s next %d", a, s.Key, s.Record.Next)
+		}
+	}
+	if err := recordNonce(ctx, v0Node(node), &types.Message{From: id, Nonce: 1}, cid.Undef); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := nextNonce(ctx, v0Node(node), from); err != nil || n != 2 {
+		t.Fatalf("expected next nonce 2, got %d, err %v", n, err)
+	}
+
+	// 之前版本按ID地址保存的记录
+	other := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+	otherID := testAddress(t, "f01001")
+	node.addAccount(other, otherID, types.FromFil(1))
+	if err := putNonceRecord(otherID.String(), &nonceRecord{Next: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := nextNonce(ctx, v0Node(node), other); err != nil || n != 7 {
+		t.Fatalf("expected migrated next nonce 7, got %d, err %v", n, err)
+	}
+	if rec, err := getNonceRecord(otherID.String()); err != nil || rec.Next != 0 {
+		t.Fatalf("ID keyed record was not migrated: %v, err %v", rec, err)
+	}
+	if rec, err := getNonceRecord(other.String()); err != nil || rec.Next != 7 {
+		t.Fatalf("migrated record: %v, err %v", rec, err)
+	}
+}
+
+func TestNonceFixDryRun(t *testing.T) {
+	from := setupTestWallet(t)
+	id := testAddress(t, "f01000")
+
+	node := newTestNode()
+	node.addAccount(from, id, types.FromFil(10))
+
+	// 消息池中有nonce 0和2，缺少1
+	for _, n := range []uint64{0, 2} {
+		node.pushed = append(node.pushed, &types.SignedMessage{Message: types.Message{From: from, To: from, Nonce: n, Value: big.Zero()}})
+	}
+	if err := putNonceRecord(from.String(), &nonceRecord{Next: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runTestApp(node, []string{"--dry-run"}, nonceFixCmd, "--fill", from.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := runTestApp(node, []string{"--dry-run"}, nonceFixCmd, from.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(node.pushed) != 2 {
+		t.Fatalf("dry run pushed %d messages", len(node.pushed)-2)
+	}
+	if len(node.called) != 1 || node.called[0].Nonce != 1 || node.called[0].Method != builtin.MethodSend {
+		t.Fatalf("expected simulated fill for nonce 1, got %v", node.called)
+	}
+
+	rec, err := getNonceRecord(from.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Next != 5 {
+		t.Fatalf("dry run reset local nonce to %d", rec.Next)
+	}
+
+	if err := runTestCommand(node, nonceFixCmd, from.String()); err != nil {
+		t.Fatal(err)
+	}
+	if rec, err = getNonceRecord(from.String()); err != nil || rec.Next != 3 {
+		t.Fatalf("expected local nonce reset to 3, got %v, err %v", rec, err)
+	}
+}
+
+func TestNonceShowWithoutPassword(t *testing.T) {
+	from := setupTestWallet(t)
+
+	node := newTestNode()
+	node.addAccount(from, testAddress(t, "f01000"), types.FromFil(1))
+
+	// 只读取本地记录和节点信息，不需要解锁钱包
+	passwdValid = false
+	defer func() { passwdValid = true }()
+
+	if err := runTestCommand(node, nonceShowCmd, from.String()); err != nil {
+		t.Fatal(err)
+	}
+}
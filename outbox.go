@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 发件箱中消息的状态
+const (
+	outboxPending  = "pending"
+	outboxIncluded = "included"
+	outboxFailed   = "failed"
+	outboxReplaced = "replaced"
+	outboxDropped  = "dropped"
+)
+
+// 发件箱记录，每条推送的签名消息一条
+type outboxEntry struct {
+	Cid      cid.Cid
+	Message  *types.SignedMessage
+	Command  string
+	Operator string
+	Time     time.Time
+
+	Status   string
+	ExitCode int64          `json:",omitempty"`
+	Height   abi.ChainEpoch `json:",omitempty"`
+	// 被替换时上链的消息
+	ReplacedBy *cid.Cid `json:",omitempty"`
+}
+
+// 推送签名消息，并记录nonce和发件箱
+func pushMessage(cctx *cli.Context, api v0api.FullNode, sm *types.SignedMessage) (cid.Cid, error) {
+	ctx := lcli.ReqContext(cctx)
+
+	c, err := api.MpoolPush(ctx, sm)
+	if err != nil {
+		fmt.Printf("推送消息上链失败，err:%v\n", err)
+		return cid.Undef, err
+	}
+
+	// 消息已经推送，记录失败不影响结果
+	if err := recordNonce(ctx, api, &sm.Message, c); err != nil {
+		fmt.Printf("保存nonce记录失败，err:%v\n", err)
+	}
+	if err := recordOutbox(c, sm); err != nil {
+		fmt.Printf("保存发件箱记录失败，err:%v\n", err)
+	}
+	if err := recordSpend(ctx, api, &sm.Message); err != nil {
+		fmt.Printf("保存转账记录失败，err:%v\n", err)
+	}
+
+	return c, nil
+}
+
+func recordOutbox(c cid.Cid, sm *types.SignedMessage) error {
+	return putOutboxEntry(&outboxEntry{
+		Cid:      c,
+		Message:  sm,
+		Command:  strings.Join(os.Args[1:], " "),
+		Operator: currentOperator(),
+		Time:     time.Now(),
+		Status:   outboxPending,
+	})
+}
+
+func currentOperator() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func putOutboxEntry(e *outboxEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return localdb.Add(db.KeyOutbox, e.Cid.String(), data)
+}
+
+// 读取发件箱中的所有记录，按推送时间倒序
+func listOutbox() ([]*outboxEntry, error) {
+	all, err := localdb.GetAll(db.KeyOutbox)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*outboxEntry
+	for k, v := range all {
+		e := new(outboxEntry)
+		if err := json.Unmarshal([]byte(v), e); err != nil {
+			return nil, xerrors.Errorf("decoding outbox entry %s: %w", k, err)
+		}
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	return entries, nil
+}
+
+var statusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "查看发件箱中未完成和最近推送的消息，并从链上更新状态",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "显示最近推送的消息条数，未完成的消息总是显示",
+			Value: 20,
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "显示所有消息",
+		},
+	},
+	Before: func(context *cli.Context) error {
+		return _initDb()
+	},
+	Action: func(cctx *cli.Context) error {
+		entries, err := listOutbox()
+		if err != nil {
+			fmt.Println("读取发件箱失败,", err)
+			return err
+		}
+
+		var show []*outboxEntry
+		for i, e := range entries {
+			if cctx.Bool("all") || i < cctx.Int("limit") || e.Status == outboxPending {
+				show = append(show, e)
+			}
+		}
+
+		if len(show) == 0 {
+			fmt.Println("发件箱中没有消息")
+			return nil
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		pending, err := api.MpoolPending(lcli.ReqContext(cctx), types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取消息池失败，err:%v\n", err)
+			return err
+		}
+		inMpool := map[cid.Cid]struct{}{}
+		for _, sm := range pending {
+			inMpool[sm.Cid()] = struct{}{}
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Time"),
+			tablewriter.Col("Cid"),
+			tablewriter.Col("From"),
+			tablewriter.Col("To"),
+			tablewriter.Col("Nonce"),
+			tablewriter.Col("Value"),
+			tablewriter.Col("Status"),
+			tablewriter.Col("Height"),
+			tablewriter.Col("Operator"),
+			tablewriter.NewLineCol("Command"))
+
+		for _, e := range show {
+			if e.Status == outboxPending {
+				if err := updateOutboxStatus(cctx, api, e, inMpool); err != nil {
+					fmt.Printf("查询消息(%s)状态失败，err:%v\n", e.Cid, err)
+				}
+			}
+
+			status := e.Status
+			switch e.Status {
+			case outboxFailed:
+				status = fmt.Sprintf("%s(%d)", e.Status, e.ExitCode)
+			case outboxReplaced:
+				if e.ReplacedBy != nil {
+					status = fmt.Sprintf("%s(%s)", e.Status, e.ReplacedBy)
+				}
+			}
+
+			row := map[string]interface{}{
+				"Time":     e.Time.Format("2006-01-02 15:04:05"),
+				"Cid":      e.Cid,
+				"From":     e.Message.Message.From,
+				"To":       e.Message.Message.To,
+				"Nonce":    e.Message.Message.Nonce,
+				"Value":    types.FIL(e.Message.Message.Value),
+				"Status":   status,
+				"Operator": e.Operator,
+				"Command":  e.Command,
+			}
+			if e.Height > 0 {
+				row["Height"] = e.Height
+			}
+			tw.Write(row)
+		}
+
+		return tw.Flush(os.Stdout)
+	},
+}
+
+// 从链上查询未完成消息的状态并保存
+func updateOutboxStatus(cctx *cli.Context, api v0api.FullNode, e *outboxEntry, inMpool map[cid.Cid]struct{}) error {
+	ctx := lcli.ReqContext(cctx)
+
+	lookup, err := api.StateSearchMsg(ctx, e.Cid)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case lookup != nil:
+		e.Height = lookup.Height
+		e.ExitCode = int64(lookup.Receipt.ExitCode)
+		switch {
+		case lookup.Message != e.Cid:
+			c := lookup.Message
+			e.Status = outboxReplaced
+			e.ReplacedBy = &c
+		case lookup.Receipt.ExitCode != 0:
+			e.Status = outboxFailed
+		default:
+			e.Status = outboxIncluded
+		}
+	default:
+		if _, ok := inMpool[e.Cid]; ok {
+			return nil
+		}
+
+		// 不在消息池中，链上nonce已经超过该消息时说明同nonce的其他消息已上链
+		act, err := api.StateGetActor(ctx, e.Message.Message.From, types.EmptyTSK)
+		if err != nil {
+			return err
+		}
+		if act.Nonce > e.Message.Message.Nonce {
+			e.Status = outboxReplaced
+		} else {
+			e.Status = outboxDropped
+		}
+	}
+
+	return putOutboxEntry(e)
+}
@@ -0,0 +1,1015 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/firefly-wallet/mnemonic"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	lbuiltin "github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/market"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/multisig"
+	"github.com/filecoin-project/lotus/chain/consensus/filcns"
+	"github.com/filecoin-project/lotus/chain/types"
+	market2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/market"
+	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v5/actors/builtin"
+	"github.com/howeyc/gopass"
+	"github.com/ipfs/go-cid"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/urfave/cli/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	policyKey = "policy"
+	// 设置过策略的标记，保存在KeyCommon中，策略记录缺失时拒绝签名
+	policyMarkerKey = "policyMarker"
+	// 用于校验策略密码的明文
+	policyPasswordCheck = "firefly-wallet-policy"
+
+	policyReject   = "reject"
+	policyPassword = "password"
+)
+
+// 签名前检查的转账策略
+type spendPolicy struct {
+	// 所有地址24小时内的转账总额上限(FIL)
+	DailyLimit string `json:",omitempty"`
+	// 单个地址24小时内的转账上限(FIL)
+	AddressLimits map[string]string `json:",omitempty"`
+	// 目标地址白名单，不为空时只能转给白名单和钱包中的地址
+	Allow []string `json:",omitempty"`
+	// 目标地址黑名单
+	Deny []string `json:",omitempty"`
+	// 钱包之外的已知地址，用于方法规则的KnownAddresses检查
+	Known []string `json:",omitempty"`
+	// 按方法名称的规则，例如 ChangeOwnerAddress
+	Methods map[string]methodRule `json:",omitempty"`
+	// 必须指定最大手续费
+	RequireMaxFee bool `json:",omitempty"`
+	// 违反策略时的处理方式：reject 拒绝签名，password 输入策略密码后允许
+	OnViolation string `json:",omitempty"`
+}
+
+type methodRule struct {
+	// 禁止调用该方法
+	Deny bool `json:",omitempty"`
+	// 参数中的地址必须是钱包地址或Known中的地址
+	KnownAddresses bool `json:",omitempty"`
+}
+
+// 数据库中保存的策略，使用由助记词派生的密钥计算MAC，防止绕过policy命令直接修改
+type policyRecord struct {
+	Policy   json.RawMessage
+	Password []byte `json:",omitempty"`
+	Mac      []byte
+}
+
+// 转账记录，用于计算24小时转账额度。每条消息保存一组记录，包括多签提案和提现实际转出的金额
+type spendRecord struct {
+	Time  time.Time
+	From  string
+	To    string
+	Value abi.TokenAmount
+}
+
+// 本次运行中已输入策略密码允许违反策略的签名
+var policyOverride bool
+
+func policyMac(policy, password []byte) []byte {
+	key := sha256.Sum256(append([]byte(policyPasswordCheck), localMnenoic...))
+	m := hmac.New(sha256.New, key[:])
+	m.Write(policy)   //nolint:errcheck
+	m.Write(password) //nolint:errcheck
+	return m.Sum(nil)
+}
+
+func policyMarker() []byte {
+	return policyMac([]byte(policyMarkerKey), nil)
+}
+
+// 读取策略，未设置策略时返回nil。设置过策略但记录缺失时返回错误
+func loadPolicy() (*spendPolicy, *policyRecord, error) {
+	if localdb == nil {
+		return nil, nil, nil
+	}
+
+	data, err := localdb.Get(db.KeyPolicy, policyKey)
+	if err != nil {
+		if !xerrors.Is(err, errors.ErrNotFound) {
+			return nil, nil, err
+		}
+
+		_, err := localdb.Get(db.KeyCommon, policyMarkerKey)
+		if err == nil {
+			return nil, nil, xerrors.New("policy has been removed outside of the policy command")
+		}
+		if !xerrors.Is(err, errors.ErrNotFound) {
+			return nil, nil, err
+		}
+		return nil, nil, nil
+	}
+
+	rec := new(policyRecord)
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, nil, xerrors.Errorf("decoding policy: %w", err)
+	}
+	if !hmac.Equal(rec.Mac, policyMac(rec.Policy, rec.Password)) {
+		return nil, nil, xerrors.New("policy has been modified outside of the policy command")
+	}
+
+	p := new(spendPolicy)
+	if err := json.Unmarshal(rec.Policy, p); err != nil {
+		return nil, nil, xerrors.Errorf("decoding policy: %w", err)
+	}
+
+	// 之前版本设置的策略没有标记，补充写入
+	if m, err := localdb.Get(db.KeyCommon, policyMarkerKey); err != nil || !hmac.Equal(m, policyMarker()) {
+		if err := localdb.Add(db.KeyCommon, policyMarkerKey, policyMarker()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return p, rec, nil
+}
+
+func savePolicy(p *spendPolicy, password []byte) error {
+	pb, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&policyRecord{
+		Policy:   pb,
+		Password: password,
+		Mac:      policyMac(pb, password),
+	})
+	if err != nil {
+		return err
+	}
+
+	b := localdb.NewBatch()
+	b.Add(db.KeyPolicy, policyKey, data)
+	b.Add(db.KeyCommon, policyMarkerKey, policyMarker())
+	return b.Commit()
+}
+
+// 校验策略中的金额和地址
+func (p *spendPolicy) validate() error {
+	parse := func(s string) error {
+		if s == "" {
+			return nil
+		}
+		_, err := types.ParseFIL(s)
+		return err
+	}
+
+	if err := parse(p.DailyLimit); err != nil {
+		return xerrors.Errorf("DailyLimit: %w", err)
+	}
+	for a, l := range p.AddressLimits {
+		if _, err := address.NewFromString(a); err != nil {
+			return xerrors.Errorf("AddressLimits %s: %w", a, err)
+		}
+		if err := parse(l); err != nil {
+			return xerrors.Errorf("AddressLimits %s: %w", a, err)
+		}
+	}
+	for name, list := range map[string][]string{"Allow": p.Allow, "Deny": p.Deny, "Known": p.Known} {
+		for _, a := range list {
+			if _, err := address.NewFromString(a); err != nil {
+				return xerrors.Errorf("%s %s: %w", name, a, err)
+			}
+		}
+	}
+
+	switch p.OnViolation {
+	case "", policyReject, policyPassword:
+	default:
+		return xerrors.Errorf("unknown OnViolation %q", p.OnViolation)
+	}
+	return nil
+}
+
+// 策略检查时通过节点解析地址和actor类型，避免使用地址的另一种形式绕过策略。
+// api为nil时（离线签名）无法解析，只能比较相同形式的地址
+type policyResolver struct {
+	ctx   context.Context
+	api   v0api.FullNode
+	ids   map[address.Address]address.Address
+	keys  map[address.Address]address.Address
+	codes map[address.Address]cid.Cid
+}
+
+func newPolicyResolver(ctx context.Context, api v0api.FullNode) *policyResolver {
+	return &policyResolver{
+		ctx:   ctx,
+		api:   api,
+		ids:   map[address.Address]address.Address{},
+		keys:  map[address.Address]address.Address{},
+		codes: map[address.Address]cid.Cid{},
+	}
+}
+
+// 地址的ID形式，链上不存在的地址返回原地址。离线时ok为false
+func (r *policyResolver) id(a address.Address) (address.Address, bool, error) {
+	if r.api == nil {
+		return a, false, nil
+	}
+	if id, ok := r.ids[a]; ok {
+		return id, true, nil
+	}
+
+	id, err := r.api.StateLookupID(r.ctx, a, types.EmptyTSK)
+	if err != nil {
+		if !strings.Contains(err.Error(), "actor not found") {
+			return address.Undef, false, xerrors.Errorf("resolving %s: %w", a, err)
+		}
+		id = a
+	}
+	r.ids[a] = id
+	return id, true, nil
+}
+
+// 账户地址的公钥地址，用于判断ID形式的地址是否是钱包地址。离线或不是账户地址时返回原地址
+func (r *policyResolver) key(a address.Address) address.Address {
+	if r.api == nil || a.Protocol() != address.ID {
+		return a
+	}
+	if k, ok := r.keys[a]; ok {
+		return k
+	}
+
+	k, err := r.api.StateAccountKey(r.ctx, a, types.EmptyTSK)
+	if err != nil {
+		k = a
+	}
+	r.keys[a] = k
+	return k
+}
+
+// 地址的actor代码，离线或查询失败时返回false
+func (r *policyResolver) code(a address.Address) (cid.Cid, bool) {
+	if r.api == nil {
+		return cid.Undef, false
+	}
+	if c, ok := r.codes[a]; ok {
+		return c, c.Defined()
+	}
+
+	var c cid.Cid
+	if act, err := r.api.StateGetActor(r.ctx, a, types.EmptyTSK); err == nil {
+		c = act.Code
+	}
+	r.codes[a] = c
+	return c, c.Defined()
+}
+
+// 地址在策略中比较时使用的形式：在线时为ID地址，离线时为原地址
+func (r *policyResolver) canonical(s string) (address.Address, error) {
+	a, err := address.NewFromString(s)
+	if err != nil {
+		return address.Undef, err
+	}
+	id, _, err := r.id(a)
+	return id, err
+}
+
+// a是否在list中。离线时只能比较相同形式的地址，a或list中有ID地址且没有找到时无法确定，unsure为true
+func (r *policyResolver) inList(list []string, a address.Address) (found, unsure bool, err error) {
+	key, online, err := r.id(a)
+	if err != nil {
+		return false, false, err
+	}
+
+	hasID := a.Protocol() == address.ID
+	for _, s := range list {
+		e, err := address.NewFromString(s)
+		if err != nil {
+			return false, false, err
+		}
+		if e.Protocol() == address.ID {
+			hasID = true
+		}
+
+		id, _, err := r.id(e)
+		if err != nil {
+			return false, false, err
+		}
+		if id == key {
+			return true, false, nil
+		}
+	}
+	return false, !online && hasID, nil
+}
+
+// 地址是否是钱包中的地址，ID形式的地址在线时解析为公钥地址后比较
+func (r *policyResolver) managed(managed map[string]string, a address.Address) bool {
+	_, ok := managed[r.key(a).String()]
+	return ok
+}
+
+// 检查消息是否违反策略，返回所有违反的规则
+func (p *spendPolicy) check(r *policyResolver, msg *types.Message) ([]string, error) {
+	managed, err := localdb.GetAll(db.KeyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	violations, err := p.checkCall(r, msg, managed)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := p.checkLimits(r, msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return dedupe(append(violations, limits...)), nil
+}
+
+// 检查目标地址和调用的方法，多签提案中的内部调用同样检查
+func (p *spendPolicy) checkCall(r *policyResolver, msg *types.Message, managed map[string]string) ([]string, error) {
+	var violations []string
+
+	known := func(a address.Address) (bool, error) {
+		if r.managed(managed, a) {
+			return true, nil
+		}
+		for _, list := range [][]string{p.Known, p.Allow} {
+			found, _, err := r.inList(list, a)
+			if err != nil || found {
+				return found, err
+			}
+		}
+		return false, nil
+	}
+
+	denied, unsure, err := r.inList(p.Deny, msg.To)
+	if err != nil {
+		return nil, err
+	}
+	if denied {
+		violations = append(violations, fmt.Sprintf("目标地址%s在黑名单中", msg.To))
+	} else if unsure {
+		violations = append(violations, fmt.Sprintf("离线签名无法解析目标地址%s，不能检查黑名单", msg.To))
+	}
+
+	if len(p.Allow) > 0 && !r.managed(managed, msg.To) {
+		allowed, unsure, err := r.inList(p.Allow, msg.To)
+		if err != nil {
+			return nil, err
+		}
+		if unsure {
+			violations = append(violations, fmt.Sprintf("离线签名无法解析目标地址%s，不能检查白名单", msg.To))
+		} else if !allowed {
+			violations = append(violations, fmt.Sprintf("目标地址%s不在白名单中", msg.To))
+		}
+	}
+
+	// 方法编号在不同actor中可能对应不同方法，所有同编号的方法规则都需要满足
+	if msg.Method != 0 {
+		for _, methods := range filcns.NewActorRegistry().Methods {
+			meta, ok := methods[msg.Method]
+			if !ok {
+				continue
+			}
+			rule, ok := p.Methods[meta.Name]
+			if !ok {
+				continue
+			}
+
+			if rule.Deny {
+				violations = append(violations, fmt.Sprintf("禁止调用方法%s", meta.Name))
+			}
+			if rule.KnownAddresses {
+				for _, a := range paramAddresses(meta.Params, msg.Params) {
+					ok, err := known(a)
+					if err != nil {
+						return nil, err
+					}
+					if !ok {
+						violations = append(violations, fmt.Sprintf("方法%s的参数地址%s不是已知地址", meta.Name, a))
+					}
+				}
+			}
+		}
+	}
+
+	// 多签Propose中的内部调用同样需要满足策略
+	if inner := proposedMessage(r, msg); inner != nil {
+		vs, err := p.checkCall(r, inner, managed)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, vs...)
+	}
+
+	return violations, nil
+}
+
+// 检查消息实际转出的金额是否超过24小时限额，prior为同一批次中尚未推送的之前消息的转账
+func (p *spendPolicy) checkLimits(r *policyResolver, msg *types.Message, prior []spendRecord) ([]string, error) {
+	outs := spendOutflows(r, msg)
+	if len(outs) == 0 {
+		return nil, nil
+	}
+
+	recorded, total, err := dailySpent(spendKey(msg))
+	if err != nil {
+		return nil, err
+	}
+
+	// 转账记录和限额中的地址统一为ID地址后比较
+	spent := map[address.Address]abi.TokenAmount{}
+	add := func(m map[address.Address]abi.TokenAmount, from string, v abi.TokenAmount) error {
+		k, err := r.canonical(from)
+		if err != nil {
+			return err
+		}
+		if s, ok := m[k]; ok {
+			m[k] = big.Add(s, v)
+		} else {
+			m[k] = v
+		}
+		return nil
+	}
+	for from, v := range recorded {
+		if err := add(spent, from, v); err != nil {
+			return nil, err
+		}
+	}
+	for _, o := range prior {
+		total = big.Add(total, o.Value)
+		if err := add(spent, o.From, o.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	limits := map[address.Address]string{}
+	limitIDs := false
+	for a, l := range p.AddressLimits {
+		k, err := r.canonical(a)
+		if err != nil {
+			return nil, err
+		}
+		limits[k] = l
+		limitIDs = limitIDs || k.Protocol() == address.ID
+	}
+
+	var violations []string
+	var froms []address.Address
+	pending := map[address.Address]abi.TokenAmount{}
+	sum := big.Zero()
+	for _, o := range outs {
+		k, err := r.canonical(o.From)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := pending[k]; !ok {
+			froms = append(froms, k)
+			pending[k] = big.Zero()
+		}
+		pending[k] = big.Add(pending[k], o.Value)
+		sum = big.Add(sum, o.Value)
+	}
+
+	for _, from := range froms {
+		l, ok := limits[from]
+		if !ok {
+			// 离线时限额和转出地址中有ID地址，无法确定是否是同一地址
+			if r.api == nil && len(limits) > 0 && (limitIDs || from.Protocol() == address.ID) {
+				violations = append(violations, fmt.Sprintf("离线签名无法解析地址%s，不能检查单个地址限额", from))
+			}
+			continue
+		}
+		limit, _ := types.ParseFIL(l)
+		after := pending[from]
+		if s, ok := spent[from]; ok {
+			after = big.Add(s, after)
+		}
+		if after.GreaterThan(abi.TokenAmount(limit)) {
+			violations = append(violations, fmt.Sprintf("地址%s 24小时内转账%s，超过限额%s", from, types.FIL(after), types.FIL(limit)))
+		}
+	}
+	if p.DailyLimit != "" {
+		limit, _ := types.ParseFIL(p.DailyLimit)
+		if after := big.Add(total, sum); after.GreaterThan(abi.TokenAmount(limit)) {
+			violations = append(violations, fmt.Sprintf("24小时内转账总额%s，超过限额%s", types.FIL(after), types.FIL(limit)))
+		}
+	}
+	return violations, nil
+}
+
+// 多签Propose消息中提议的内部调用，其他消息返回nil。
+// 在线时按目标地址的actor代码判断，离线无法查询时按方法编号判断
+func proposedMessage(r *policyResolver, msg *types.Message) *types.Message {
+	if msg.Method != builtin.MethodsMultisig.Propose {
+		return nil
+	}
+	if c, ok := r.code(msg.To); ok {
+		if !lbuiltin.IsMultisigActor(c) {
+			return nil
+		}
+	} else if msg.To == market.Address {
+		return nil
+	}
+
+	var pp multisig.ProposeParams
+	if err := pp.UnmarshalCBOR(bytes.NewReader(msg.Params)); err != nil {
+		return nil
+	}
+	return &types.Message{
+		From:   msg.To,
+		To:     pp.To,
+		Value:  pp.Value,
+		Method: pp.Method,
+		Params: pp.Params,
+	}
+}
+
+// 消息实际转出的金额：消息本身的金额、多签提案中的转账，以及矿工和market的提现
+func spendOutflows(r *policyResolver, msg *types.Message) []spendRecord {
+	var out []spendRecord
+	add := func(from, to address.Address, v abi.TokenAmount) {
+		if !v.Nil() && v.GreaterThan(big.Zero()) {
+			out = append(out, spendRecord{From: from.String(), To: to.String(), Value: v})
+		}
+	}
+
+	add(msg.From, msg.To, msg.Value)
+
+	isMiner := msg.Method == miner.Methods.WithdrawBalance
+	if c, ok := r.code(msg.To); ok {
+		isMiner = isMiner && lbuiltin.IsStorageMinerActor(c)
+	}
+
+	switch {
+	case msg.To == market.Address && msg.Method == market.Methods.WithdrawBalance:
+		var wp market2.WithdrawBalanceParams
+		if err := wp.UnmarshalCBOR(bytes.NewReader(msg.Params)); err == nil {
+			add(wp.ProviderOrClientAddress, msg.From, wp.Amount)
+		}
+	case isMiner:
+		var wp miner2.WithdrawBalanceParams
+		if err := wp.UnmarshalCBOR(bytes.NewReader(msg.Params)); err == nil {
+			add(msg.To, msg.From, wp.AmountRequested)
+		}
+	default:
+		if inner := proposedMessage(r, msg); inner != nil {
+			out = append(out, spendOutflows(r, inner)...)
+		}
+	}
+	return out
+}
+
+// 解码方法参数，返回参数中的所有地址；参数类型不匹配时返回空
+func paramAddresses(typ reflect.Type, params []byte) []address.Address {
+	if typ == nil || len(params) == 0 {
+		return nil
+	}
+
+	p, ok := reflect.New(typ.Elem()).Interface().(cbg.CBORUnmarshaler)
+	if !ok || p.UnmarshalCBOR(bytes.NewReader(params)) != nil {
+		return nil
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil
+	}
+
+	var out []address.Address
+	var walk func(interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			if a, err := address.NewFromString(v); err == nil {
+				out = append(out, a)
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(v)
+	return out
+}
+
+func dedupe(list []string) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, s := range list {
+		if _, ok := seen[s]; !ok {
+			seen[s] = struct{}{}
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// 24小时内每个地址的转账金额和所有地址的转账总额，不包括self（同一nonce的替换消息只计算一次）
+func dailySpent(self string) (map[string]abi.TokenAmount, abi.TokenAmount, error) {
+	all, err := localdb.GetAll(db.KeySpend)
+	if err != nil {
+		return nil, big.Zero(), err
+	}
+
+	spent, total := map[string]abi.TokenAmount{}, big.Zero()
+	since := time.Now().Add(-24 * time.Hour)
+	for k, v := range all {
+		if k == self {
+			continue
+		}
+
+		// 之前版本每条消息只保存一条记录
+		var rs []spendRecord
+		if err := json.Unmarshal([]byte(v), &rs); err != nil {
+			var r spendRecord
+			if err := json.Unmarshal([]byte(v), &r); err != nil {
+				continue
+			}
+			rs = []spendRecord{r}
+		}
+
+		for _, r := range rs {
+			if r.Value.Nil() || r.Time.Before(since) {
+				continue
+			}
+			total = big.Add(total, r.Value)
+			if s, ok := spent[r.From]; ok {
+				spent[r.From] = big.Add(s, r.Value)
+			} else {
+				spent[r.From] = r.Value
+			}
+		}
+	}
+	return spent, total, nil
+}
+
+func spendKey(msg *types.Message) string {
+	return fmt.Sprintf("%s/%d", msg.From, msg.Nonce)
+}
+
+// 签名前执行策略检查，违反策略时拒绝或要求输入策略密码。离线签名时api为nil
+func enforcePolicy(ctx context.Context, api v0api.FullNode, msg *types.Message) error {
+	p, rec, err := loadPolicy()
+	if err != nil {
+		fmt.Println("读取转账策略失败，拒绝签名:", err)
+		return err
+	}
+	if p == nil {
+		return nil
+	}
+
+	violations, err := p.check(newPolicyResolver(ctx, api), msg)
+	if err != nil {
+		fmt.Println("检查转账策略失败，拒绝签名:", err)
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return overridePolicy(p, rec, violations)
+}
+
+// 批量签名前检查所有消息，之前消息的转账计入之后消息的额度。label返回违反规则时显示的消息名称，例如行号
+func enforceBatchPolicy(ctx context.Context, api v0api.FullNode, msgs []*types.Message, label func(i int) string) error {
+	p, rec, err := loadPolicy()
+	if err != nil {
+		fmt.Println("读取转账策略失败，拒绝签名:", err)
+		return err
+	}
+	if p == nil {
+		return nil
+	}
+
+	managed, err := localdb.GetAll(db.KeyAddr)
+	if err != nil {
+		return err
+	}
+
+	r := newPolicyResolver(ctx, api)
+
+	var violations []string
+	var prior []spendRecord
+	for i, msg := range msgs {
+		vs, err := p.checkCall(r, msg, managed)
+		if err != nil {
+			fmt.Println("检查转账策略失败，拒绝签名:", err)
+			return err
+		}
+		limits, err := p.checkLimits(r, msg, prior)
+		if err != nil {
+			fmt.Println("检查转账策略失败，拒绝签名:", err)
+			return err
+		}
+		for _, v := range dedupe(append(vs, limits...)) {
+			violations = append(violations, fmt.Sprintf("%s: %s", label(i), v))
+		}
+		prior = append(prior, spendOutflows(r, msg)...)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return overridePolicy(p, rec, violations)
+}
+
+// 签名任意数据时无法检查内容，设置了策略时按违反策略处理
+func enforceRawPolicy() error {
+	p, rec, err := loadPolicy()
+	if err != nil {
+		fmt.Println("读取转账策略失败，拒绝签名:", err)
+		return err
+	}
+	if p == nil {
+		return nil
+	}
+	return overridePolicy(p, rec, []string{"已设置转账策略，签名任意数据无法检查策略"})
+}
+
+// 违反策略时拒绝签名，策略允许时输入策略密码后继续
+func overridePolicy(p *spendPolicy, rec *policyRecord, violations []string) error {
+	if policyOverride {
+		return nil
+	}
+
+	fmt.Println("消息违反转账策略:")
+	for _, v := range violations {
+		fmt.Println("  -", v)
+	}
+
+	if p.OnViolation != policyPassword || len(rec.Password) == 0 {
+		return xerrors.Errorf("policy violation: %s", strings.Join(violations, "; "))
+	}
+
+	fmt.Print("请输入策略密码以允许本次签名:")
+	pw, err := gopass.GetPasswdMasked()
+	if err != nil {
+		return err
+	}
+	if err := checkPolicyPassword(rec, pw); err != nil {
+		fmt.Println("策略密码错误，拒绝签名")
+		return err
+	}
+
+	policyOverride = true
+	return nil
+}
+
+// 记录消息实际转出的金额，在消息推送成功（离线签名时为写出签名文件，api为nil）后调用
+func recordSpend(ctx context.Context, api v0api.FullNode, msg *types.Message) error {
+	if localdb == nil {
+		return nil
+	}
+
+	outs := spendOutflows(newPolicyResolver(ctx, api), msg)
+	if len(outs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for i := range outs {
+		outs[i].Time = now
+	}
+
+	data, err := json.Marshal(outs)
+	if err != nil {
+		return err
+	}
+	return localdb.Add(db.KeySpend, spendKey(msg), data)
+}
+
+// 策略要求必须指定最大手续费。未解锁钱包时（例如--unsigned-out）由签名的机器检查
+func policyRequiresMaxFee() (bool, error) {
+	if len(localMnenoic) == 0 {
+		return false, nil
+	}
+
+	p, _, err := loadPolicy()
+	if err != nil {
+		return false, err
+	}
+	return p != nil && p.RequireMaxFee, nil
+}
+
+func checkPolicyPassword(rec *policyRecord, pw []byte) error {
+	plain, err := mnemonic.Decrypt(rec.Password, pw)
+	if err != nil || string(plain) != policyPasswordCheck {
+		return xerrors.New("wrong policy password")
+	}
+	return nil
+}
+
+// 修改策略前，已设置策略密码时需要输入策略密码
+func authPolicyEdit() (*policyRecord, error) {
+	_, rec, err := loadPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil || len(rec.Password) == 0 {
+		return rec, nil
+	}
+
+	fmt.Print("请输入策略密码:")
+	pw, err := gopass.GetPasswdMasked()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPolicyPassword(rec, pw); err != nil {
+		fmt.Println("策略密码错误")
+		return nil, err
+	}
+	return rec, nil
+}
+
+var policyCmd = &cli.Command{
+	Name:  "policy",
+	Usage: "查看和修改签名前检查的转账策略",
+	Subcommands: []*cli.Command{
+		policyShowCmd,
+		policySetCmd,
+		policyPasswdCmd,
+	},
+}
+
+var policyShowCmd = &cli.Command{
+	Name:  "show",
+	Usage: "以JSON格式显示当前策略",
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		p, rec, err := loadPolicy()
+		if err != nil {
+			fmt.Println("读取转账策略失败:", err)
+			return err
+		}
+		if p == nil {
+			fmt.Println("未设置转账策略")
+			return nil
+		}
+
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		fmt.Println("策略密码:", len(rec.Password) > 0)
+		return nil
+	},
+}
+
+var policySetCmd = &cli.Command{
+	Name:      "set",
+	Usage:     "从JSON文件设置策略，需要输入钱包密码，已设置策略密码时还需要输入策略密码",
+	ArgsUsage: "<policy.json>",
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定策略文件")
+			return fmt.Errorf("must pass policy file")
+		}
+
+		data, err := ioutil.ReadFile(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		p := new(spendPolicy)
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(p); err != nil {
+			fmt.Println("解析策略文件失败:", err)
+			return err
+		}
+		if err := p.validate(); err != nil {
+			fmt.Println("策略不合法:", err)
+			return err
+		}
+
+		rec, err := authPolicyEdit()
+		if err != nil {
+			return err
+		}
+
+		var pw []byte
+		if rec != nil {
+			pw = rec.Password
+		}
+		if p.OnViolation == policyPassword && len(pw) == 0 {
+			fmt.Println("警告: 未设置策略密码，违反策略的签名都会被拒绝，可使用 policy passwd 设置")
+		}
+
+		if err := savePolicy(p, pw); err != nil {
+			fmt.Println("保存策略失败:", err)
+			return err
+		}
+		fmt.Println("策略已更新")
+		return nil
+	},
+}
+
+var policyPasswdCmd = &cli.Command{
+	Name:  "passwd",
+	Usage: "设置或修改策略密码，策略密码应由钱包密码持有人以外的人保管",
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		rec, err := authPolicyEdit()
+		if err != nil {
+			return err
+		}
+
+		p := new(spendPolicy)
+		if rec != nil {
+			if err := json.Unmarshal(rec.Policy, p); err != nil {
+				return err
+			}
+		}
+
+		fmt.Print("请输入新的策略密码:")
+		pw, err := gopass.GetPasswdMasked()
+		if err != nil {
+			return err
+		}
+		if len(pw) < 6 {
+			fmt.Println("策略密码长度至少6位")
+			return xerrors.New("policy password too short")
+		}
+		fmt.Print("请再次输入新的策略密码:")
+		pw2, err := gopass.GetPasswdMasked()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(pw, pw2) {
+			fmt.Println("两次输入的密码不一致")
+			return xerrors.New("passwords do not match")
+		}
+		if bytes.Equal(pw, passwd) {
+			fmt.Println("策略密码不能与钱包密码相同")
+			return xerrors.New("policy password must differ from wallet password")
+		}
+
+		enc, err := mnemonic.EncryptData([]byte(policyPasswordCheck), pw)
+		if err != nil {
+			return err
+		}
+
+		if err := savePolicy(p, enc); err != nil {
+			fmt.Println("保存策略失败:", err)
+			return err
+		}
+		fmt.Println("策略密码已更新")
+		return nil
+	},
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/market"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/multisig"
+	"github.com/filecoin-project/lotus/chain/types"
+	market2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/market"
+	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v5/actors/builtin"
+	"golang.org/x/xerrors"
+	"testing"
+	"time"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	from := setupTestWallet(t)
+	to := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+	denied := testAddress(t, "f1ys5qqiciehcml3sp764ymbbytfn3qoar5fo3iwy")
+	msig := testAddress(t, "f02000")
+	maddr := testAddress(t, "f02420")
+	fromID := testAddress(t, "f01000")
+	deniedID := testAddress(t, "f01005")
+
+	node := newTestNode()
+	node.addAccount(from, fromID, types.FromFil(100))
+	node.addAccount(denied, deniedID, big.Zero())
+	node.actors[msig] = &types.Actor{Code: builtin.MultisigActorCodeID, Balance: types.FromFil(100)}
+	node.actors[maddr] = &types.Actor{Code: builtin.StorageMinerActorCodeID, Balance: types.FromFil(100)}
+
+	if err := savePolicy(&spendPolicy{
+		DailyLimit:    "10",
+		AddressLimits: map[string]string{from.String(): "5", msig.String(): "6"},
+		Deny:          []string{denied.String()},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	propose := func(to address.Address, value abi.TokenAmount) []byte {
+		b, err := actors.SerializeParams(&multisig.ProposeParams{To: to, Value: value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	minerWithdraw, aerr := actors.SerializeParams(&miner2.WithdrawBalanceParams{AmountRequested: types.FromFil(8)})
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+	marketWithdraw, aerr := actors.SerializeParams(&market2.WithdrawBalanceParams{ProviderOrClientAddress: maddr, Amount: types.FromFil(8)})
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+
+	// 已经推送的转账
+	if err := recordSpend(context.Background(), v0Node(node), &types.Message{From: from, To: to, Nonce: 0, Value: types.FromFil(3)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		offline    bool
+		msg        *types.Message
+		violations int
+	}{
+		{"within limits", false, &types.Message{From: from, To: to, Nonce: 1, Value: types.FromFil(2)}, 0},
+		{"address limit", false, &types.Message{From: from, To: to, Nonce: 1, Value: types.FromFil(3)}, 1},
+		// 替换同一nonce的消息不重复计算
+		{"replace", false, &types.Message{From: from, To: to, Nonce: 0, Value: types.FromFil(5)}, 0},
+		{"denied", false, &types.Message{From: from, To: denied, Nonce: 1, Value: big.Zero()}, 1},
+		{"propose within limits", false, &types.Message{From: from, To: msig, Nonce: 1, Method: builtin.MethodsMultisig.Propose, Params: propose(to, types.FromFil(6))}, 0},
+		{"propose over limits", false, &types.Message{From: from, To: msig, Nonce: 1, Method: builtin.MethodsMultisig.Propose, Params: propose(to, types.FromFil(8))}, 2},
+		{"propose denied", false, &types.Message{From: from, To: msig, Nonce: 1, Method: builtin.MethodsMultisig.Propose, Params: propose(denied, big.Zero())}, 1},
+		{"miner withdraw", false, &types.Message{From: from, To: maddr, Nonce: 1, Method: miner.Methods.WithdrawBalance, Params: minerWithdraw}, 1},
+		{"market withdraw", false, &types.Message{From: from, To: market.Address, Nonce: 1, Method: market.Methods.WithdrawBalance, Params: marketWithdraw}, 1},
+		// 方法编号为2，但目标不是多签钱包
+		{"not propose", false, &types.Message{From: from, To: maddr, Nonce: 1, Method: builtin.MethodsMultisig.Propose, Params: propose(to, types.FromFil(8))}, 0},
+		// 使用ID地址不能绕过黑名单和单个地址限额
+		{"denied id target", false, &types.Message{From: from, To: deniedID, Nonce: 1, Value: big.Zero()}, 1},
+		{"id sender", false, &types.Message{From: fromID, To: to, Nonce: 1, Value: types.FromFil(3)}, 1},
+		{"id sender within limits", false, &types.Message{From: fromID, To: to, Nonce: 1, Value: types.FromFil(2)}, 0},
+		// 离线签名无法解析ID地址，按违反策略处理
+		{"offline", true, &types.Message{From: from, To: to, Nonce: 1, Value: types.FromFil(2)}, 0},
+		{"offline denied", true, &types.Message{From: from, To: denied, Nonce: 1, Value: big.Zero()}, 1},
+		{"offline id target", true, &types.Message{From: from, To: deniedID, Nonce: 1, Value: big.Zero()}, 1},
+		{"offline id sender", true, &types.Message{From: fromID, To: to, Nonce: 1, Value: big.Zero()}, 0},
+		{"offline id sender value", true, &types.Message{From: fromID, To: to, Nonce: 1, Value: types.FromFil(1)}, 1},
+	}
+
+	p, _, err := loadPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range tests {
+		r := newPolicyResolver(context.Background(), v0Node(node))
+		if tc.offline {
+			r = newPolicyResolver(context.Background(), nil)
+		}
+		violations, err := p.check(r, tc.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(violations) != tc.violations {
+			t.Errorf("%s: expected %d violations, got %v", tc.name, tc.violations, violations)
+		}
+	}
+}
+
+func TestDailySpent(t *testing.T) {
+	from := setupTestWallet(t)
+	to := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+	msig := testAddress(t, "f02000")
+
+	params, aerr := actors.SerializeParams(&multisig.ProposeParams{To: to, Value: types.FromFil(4)})
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+	if err := recordSpend(context.Background(), nil, &types.Message{From: from, To: msig, Nonce: 0, Value: types.FromFil(1), Method: builtin.MethodsMultisig.Propose, Params: params}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 之前版本保存的单条记录
+	put := func(key string, r spendRecord) {
+		b, err := json.Marshal(&r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := localdb.Add(db.KeySpend, key, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(from.String()+"/1", spendRecord{Time: time.Now(), From: from.String(), To: to.String(), Value: types.FromFil(2)})
+	put(from.String()+"/2", spendRecord{Time: time.Now().Add(-25 * time.Hour), From: from.String(), To: to.String(), Value: types.FromFil(100)})
+
+	tests := []struct {
+		self  string
+		from  abi.TokenAmount
+		msig  abi.TokenAmount
+		total abi.TokenAmount
+	}{
+		{"", types.FromFil(3), types.FromFil(4), types.FromFil(7)},
+		{from.String() + "/0", types.FromFil(2), big.Zero(), types.FromFil(2)},
+		{from.String() + "/1", types.FromFil(1), types.FromFil(4), types.FromFil(5)},
+	}
+
+	for _, tc := range tests {
+		spent, total, err := dailySpent(tc.self)
+		if err != nil {
+			t.Fatal(err)
+		}
+		get := func(a string) abi.TokenAmount {
+			if v, ok := spent[a]; ok {
+				return v
+			}
+			return big.Zero()
+		}
+		if !get(from.String()).Equals(tc.from) || !get(msig.String()).Equals(tc.msig) || !total.Equals(tc.total) {
+			t.Errorf("self %q: from %s msig %s total %s", tc.self, types.FIL(get(from.String())), types.FIL(get(msig.String())), types.FIL(total))
+		}
+	}
+}
+
+func TestPolicyFailClosed(t *testing.T) {
+	from := setupTestWallet(t)
+	to := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+	msg := &types.Message{From: from, To: to, Value: types.FromFil(1)}
+
+	if err := enforcePolicy(context.Background(), nil, msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := enforceRawPolicy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := savePolicy(&spendPolicy{DailyLimit: "100"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := enforcePolicy(context.Background(), nil, msg); err != nil {
+		t.Fatal(err)
+	}
+	// 设置策略后不能签名任意数据
+	if err := enforceRawPolicy(); err == nil {
+		t.Fatal("expected raw signing to be refused")
+	}
+
+	// 策略记录被删除后拒绝签名
+	if err := localdb.Del(db.KeyPolicy, policyKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := enforcePolicy(context.Background(), nil, msg); err == nil {
+		t.Fatal("expected missing policy to fail closed")
+	}
+}
+
+func TestRecordSpendAfterPush(t *testing.T) {
+	from := setupTestWallet(t)
+	to := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+
+	node := newTestNode()
+	node.addAccount(from, testAddress(t, "f01000"), types.FromFil(10))
+
+	args := []string{"--yes", "--from", from.String(), "--to", to.String(), "--amount", "2"}
+
+	node.pushErr = xerrors.New("mpool full")
+	if err := runTestCommand(node, sendCmd, args...); err == nil {
+		t.Fatal("expected push error")
+	}
+	if _, total, err := dailySpent(""); err != nil || !total.IsZero() {
+		t.Fatalf("failed push recorded spend %s, err %v", types.FIL(total), err)
+	}
+
+	node.pushErr = nil
+	if err := runTestCommand(node, sendCmd, args...); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, err := dailySpent(""); err != nil || !total.Equals(types.FromFil(2)) {
+		t.Fatalf("expected 2 FIL spent, got %s, err %v", types.FIL(total), err)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/consensus/filcns"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
+	"github.com/urfave/cli/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// 签名前需要输入的接收地址字符数
+const confirmSuffixLen = 4
+
+var yesFlag = &cli.BoolFlag{
+	Name:  "yes",
+	Usage: "跳过签名前的确认",
+}
+
+// 以可读的形式输出消息：地址标签、ID地址和公钥地址、FIL金额、解码后的方法参数和最大手续费。
+// api为nil时（离线签名）不查询链上信息
+func previewMessage(ctx context.Context, api v0api.FullNode, msg *types.Message) {
+	fmt.Println()
+	fmt.Println("消息预览:")
+	fmt.Println("  发送方:    ", describeAddress(ctx, api, msg.From))
+	fmt.Println("  接收方:    ", describeAddress(ctx, api, msg.To))
+	fmt.Println("  金额:      ", types.FIL(msg.Value))
+
+	metas := messageMethods(ctx, api, msg)
+	var names []string
+	for _, m := range metas {
+		names = append(names, m.Name)
+	}
+	if len(names) == 0 {
+		names = append(names, "未知方法")
+	}
+	fmt.Printf("  方法:       %s (%d)\n", strings.Join(dedupe(names), " / "), msg.Method)
+
+	if len(msg.Params) > 0 {
+		params, typ := decodeParams(metas, msg.Params)
+		if params == "" {
+			fmt.Println("  参数(hex): ", hex.EncodeToString(msg.Params))
+		} else {
+			fmt.Println("  参数:")
+			for _, l := range strings.Split(params, "\n") {
+				fmt.Println("    " + l)
+			}
+
+			for _, a := range paramAddresses(typ, msg.Params) {
+				fmt.Println("  参数地址:  ", describeAddress(ctx, api, a))
+			}
+		}
+	}
+
+	fmt.Println("  Nonce:     ", msg.Nonce)
+	fmt.Println("  GasLimit:  ", msg.GasLimit)
+	fmt.Println("  GasFeeCap: ", types.FIL(msg.GasFeeCap))
+	fmt.Println("  GasPremium:", types.FIL(msg.GasPremium))
+	fmt.Println("  最大手续费:", types.FIL(big.Mul(msg.GasFeeCap, big.NewInt(msg.GasLimit))))
+	fmt.Println()
+}
+
+// 地址的标签、公钥地址和ID地址
+func describeAddress(ctx context.Context, api v0api.FullNode, a address.Address) string {
+	robust, id := a, address.Undef
+	if a.Protocol() == address.ID {
+		robust, id = address.Undef, a
+	}
+
+	if api != nil {
+		if id == address.Undef {
+			if r, err := api.StateLookupID(ctx, a, types.EmptyTSK); err == nil {
+				id = r
+			}
+		} else if r, err := api.StateAccountKey(ctx, a, types.EmptyTSK); err == nil {
+			robust = r
+		}
+	}
+
+	var parts []string
+	if robust != address.Undef {
+		parts = append(parts, robust.String())
+	}
+	if id != address.Undef {
+		parts = append(parts, "ID: "+id.String())
+	}
+
+	if robust != address.Undef && localdb != nil {
+		if fai, err := getAddressInfo(robust.String()); err == nil {
+			label := "钱包地址"
+			if fai.Label != "" {
+				label = fai.Label
+			}
+			parts = append(parts, "["+label+"]")
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// 查找消息调用的方法。能查询链上actor时返回唯一的方法，离线时返回所有actor中该编号的方法
+func messageMethods(ctx context.Context, api v0api.FullNode, msg *types.Message) []vm.MethodMeta {
+	registry := filcns.NewActorRegistry()
+
+	if api != nil {
+		if act, err := api.StateGetActor(ctx, msg.To, types.EmptyTSK); err == nil {
+			if meta, ok := registry.Methods[act.Code][msg.Method]; ok {
+				return []vm.MethodMeta{meta}
+			}
+			return nil
+		}
+	}
+
+	var out []vm.MethodMeta
+	seen := map[string]struct{}{}
+	for _, methods := range registry.Methods {
+		meta, ok := methods[msg.Method]
+		if !ok {
+			continue
+		}
+		if _, ok := seen[meta.Name+meta.Params.String()]; ok {
+			continue
+		}
+		seen[meta.Name+meta.Params.String()] = struct{}{}
+		out = append(out, meta)
+	}
+	return out
+}
+
+// 按方法参数类型解码参数，返回JSON和解码成功的类型
+func decodeParams(metas []vm.MethodMeta, params []byte) (string, reflect.Type) {
+	for _, meta := range metas {
+		if meta.Params == nil {
+			continue
+		}
+
+		p, ok := reflect.New(meta.Params.Elem()).Interface().(cbg.CBORUnmarshaler)
+		if !ok || p.UnmarshalCBOR(bytes.NewReader(params)) != nil {
+			continue
+		}
+
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			continue
+		}
+		return string(b), meta.Params
+	}
+	return "", nil
+}
+
+// 要求操作员输入地址的最后几个字符确认签名。target一般是接收地址，修改owner、worker和提现时是参数中的新地址或收款地址
+func confirmMessage(target address.Address) error {
+	return confirmAddress(os.Stdin, target)
+}
+
+func confirmAddress(in io.Reader, target address.Address) error {
+	s := target.String()
+	suffix := s
+	if len(s) > confirmSuffixLen {
+		suffix = s[len(s)-confirmSuffixLen:]
+	}
+
+	if suffix == s {
+		fmt.Printf("请输入地址 %s 确认签名: ", s)
+	} else {
+		fmt.Printf("请输入地址 %s 的最后%d个字符确认签名: ", s, confirmSuffixLen)
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return err
+	}
+
+	if strings.TrimSpace(line) != suffix {
+		fmt.Println("输入不正确，已取消")
+		return xerrors.New("confirmation does not match address")
+	}
+	return nil
+}
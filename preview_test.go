@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/filecoin-project/go-address"
+	"strings"
+	"testing"
+)
+
+func TestConfirmAddress(t *testing.T) {
+	tests := []struct {
+		target string
+		input  string
+		ok     bool
+	}{
+		// 短于4个字符的ID地址需要输入完整地址
+		{"f01", "f01\n", true},
+		{"f04", "f04\n", true},
+		{"f05", "05\n", false},
+		{"f010", "f010\n", true},
+		{"f02420", "2420\n", true},
+		{"f02420", " 2420 \n", true},
+		{"f02420", "f02420\n", false},
+		{"f02420", "2421\n", false},
+		{"f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy", "b2oy\n", true},
+		{"f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy", "b2oy", true},
+		{"f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy", "\n", false},
+		{"f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy", "", false},
+	}
+
+	for _, tc := range tests {
+		a, err := address.NewFromString(tc.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = confirmAddress(strings.NewReader(tc.input), a)
+		if (err == nil) != tc.ok {
+			t.Errorf("confirm %s with %q: ok=%v, err=%v", tc.target, tc.input, tc.ok, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/messagepool"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/specs-actors/v5/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var replaceCmd = &cli.Command{
+	Name:      "replace",
+	Usage:     "使用相同nonce和更高的gas premium替换消息池中卡住的消息",
+	ArgsUsage: "<message cid>",
+	Flags: append([]cli.Flag{
+		&cli.BoolFlag{
+			Name:  "auto",
+			Usage: "由节点重新评估gas，premium不低于替换所需的最低值",
+		},
+		waitFlag,
+		confidenceFlag,
+	}, gasFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		return replaceAction(cctx, false)
+	},
+}
+
+var cancelCmd = &cli.Command{
+	Name:      "cancel",
+	Usage:     "使用相同nonce的0金额转给自己的消息取消消息池中卡住的消息",
+	ArgsUsage: "<message cid>",
+	Flags: append([]cli.Flag{
+		waitFlag,
+		confidenceFlag,
+	}, maxFeeFlag),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		return replaceAction(cctx, true)
+	},
+}
+
+func replaceAction(cctx *cli.Context, cancel bool) error {
+	if !passwdValid {
+		fmt.Println("密码错误.")
+		return fmt.Errorf("密码错误")
+	}
+
+	if cctx.NArg() != 1 {
+		fmt.Println("必须指定消息CID")
+		return fmt.Errorf("must pass message cid")
+	}
+
+	mcid, err := cid.Decode(cctx.Args().First())
+	if err != nil {
+		fmt.Println("解析消息CID失败,", err)
+		return err
+	}
+
+	api, closer, err := lcli.GetFullNodeAPI(cctx)
+	if err != nil {
+		fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+		return err
+	}
+	defer closer()
+
+	ctx := lcli.ReqContext(cctx)
+
+	found, err := findPendingMessage(ctx, api, mcid)
+	if err != nil {
+		fmt.Printf("消息池中没有找到消息%s，err:%v\n", mcid, err)
+		return err
+	}
+
+	msg := found.Message
+	if cancel {
+		msg = types.Message{
+			From:   found.Message.From,
+			To:     found.Message.From,
+			Value:  big.Zero(),
+			Method: builtin.MethodSend,
+			Nonce:  found.Message.Nonce,
+		}
+	}
+
+	if err := bumpGas(cctx, api, &found.Message, &msg, cancel || cctx.Bool("auto")); err != nil {
+		return err
+	}
+
+	previewMessage(ctx, api, &msg)
+
+	if cctx.Bool("dry-run") {
+		return dryRunMessage(cctx, api, &msg)
+	}
+
+	sm, err := signChainMessage(ctx, api, &msg)
+	if err != nil {
+		return err
+	}
+
+	c, err := pushMessage(cctx, api, sm)
+	if err != nil {
+		return err
+	}
+
+	if cancel {
+		fmt.Printf("消息%s已被取消消息替换: %s\n", mcid, c)
+	} else {
+		fmt.Printf("消息%s已被替换: %s\n", mcid, c)
+	}
+
+	if cctx.Bool("wait") {
+		_, err := waitMessage(cctx, api, c)
+		return err
+	}
+	return nil
+}
+
+// 在消息池中查找待上链的消息
+func findPendingMessage(ctx context.Context, api v0api.FullNode, c cid.Cid) (*types.SignedMessage, error) {
+	msg, err := api.ChainGetMessage(ctx, c)
+	if err != nil {
+		return nil, xerrors.Errorf("getting message: %w", err)
+	}
+
+	pending, err := api.MpoolPending(ctx, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting pending messages: %w", err)
+	}
+
+	for _, sm := range pending {
+		if sm.Message.From == msg.From && sm.Message.Nonce == msg.Nonce {
+			if sm.Cid() != c {
+				fmt.Printf("nonce %d 在消息池中的消息为 %s\n", msg.Nonce, sm.Cid())
+			}
+			return sm, nil
+		}
+	}
+
+	return nil, xerrors.Errorf("no pending message from %s with nonce %d", msg.From, msg.Nonce)
+}
+
+// 设置替换消息的gas，premium不低于lotus消息池替换规则要求的最低值
+func bumpGas(cctx *cli.Context, api v0api.FullNode, old, msg *types.Message, auto bool) error {
+	minRBF := messagepool.ComputeMinRBF(old.GasPremium)
+
+	maxFee, err := getMaxFee(cctx)
+	if err != nil {
+		fmt.Printf("读取最大手续费失败， err:%v\n", err)
+		return err
+	}
+
+	if auto {
+		est := *msg
+		est.GasFeeCap = big.Zero()
+		est.GasPremium = big.Zero()
+		if msg.To != old.To || msg.Method != old.Method {
+			est.GasLimit = 0
+		}
+
+		// estimateMessageGas会应用命令行指定的gas参数
+		ret, err := estimateMessageGas(cctx, api, &est)
+		if err != nil {
+			return err
+		}
+
+		msg.GasLimit = ret.GasLimit
+		msg.GasPremium = big.Max(ret.GasPremium, minRBF)
+		msg.GasFeeCap = big.Max(ret.GasFeeCap, msg.GasPremium)
+	} else {
+		msg.GasPremium = minRBF
+		if err := applyGasFlags(cctx, msg); err != nil {
+			fmt.Printf("解析gas参数失败， err:%v\n", err)
+			return err
+		}
+
+		if msg.GasPremium.LessThan(minRBF) {
+			fmt.Printf("gas premium至少为%s才能替换消息\n", minRBF)
+			return xerrors.Errorf("gas premium %s is below minimum replace-by-fee premium %s", msg.GasPremium, minRBF)
+		}
+		msg.GasFeeCap = big.Max(msg.GasFeeCap, msg.GasPremium)
+	}
+
+	if err := checkMaxFee(msg, maxFee); err != nil {
+		fmt.Printf("最大手续费超过限制，拒绝签名: %v\n", err)
+		return err
+	}
+
+	return nil
+}
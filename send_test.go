@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	"testing"
+)
+
+func TestSendFlags(t *testing.T) {
+	from := setupTestWallet(t)
+	to := testAddress(t, "f1d5rhpq5icldkw4djdis5oufefutgyqkqunmb2oy")
+
+	node := newTestNode()
+	node.addAccount(from, testAddress(t, "f01000"), types.FromFil(10))
+
+	suffix := to.String()[len(to.String())-4:] + "\n"
+
+	tests := []struct {
+		args   []string
+		stdin  string
+		ok     bool
+		pushed bool
+	}{
+		// 缺少参数时只提示，不发送
+		{[]string{"--to", to.String(), "--amount", "1"}, "", true, false},
+		{[]string{"--from", from.String(), "--amount", "1"}, "", true, false},
+		{[]string{"--from", from.String(), "--to", to.String()}, "", true, false},
+		{[]string{"--from", from.String(), "--to", to.String(), "--all", "--amount", "1"}, "", false, false},
+		{[]string{"--from", from.String(), "--to", from.String(), "--amount", "1"}, "", false, false},
+		{[]string{"--from", from.String(), "--to", to.String(), "--amount", "x"}, "", false, false},
+		// 转账需要输入接收地址确认
+		{[]string{"--from", from.String(), "--to", to.String(), "--amount", "1"}, "xxxx\n", false, false},
+		{[]string{"--from", from.String(), "--to", to.String(), "--amount", "1"}, suffix, true, true},
+		// --all 不需要指定 --amount
+		{[]string{"--yes", "--from", from.String(), "--to", to.String(), "--all"}, "", true, true},
+	}
+
+	for _, tc := range tests {
+		before := len(node.pushed)
+		if tc.stdin != "" {
+			withStdin(t, tc.stdin)
+		}
+
+		err := runTestCommand(node, sendCmd, tc.args...)
+		if (err == nil) != tc.ok {
+			t.Errorf("send %v: ok=%v, err=%v", tc.args, tc.ok, err)
+		}
+		if pushed := len(node.pushed) > before; pushed != tc.pushed {
+			t.Errorf("send %v: pushed=%v, want %v", tc.args, pushed, tc.pushed)
+		}
+	}
+
+	if len(node.pushed) != 2 {
+		t.Fatalf("expected 2 pushed messages, got %d", len(node.pushed))
+	}
+	if v := node.pushed[0].Message.Value; !v.Equals(types.FromFil(1)) {
+		t.Errorf("unexpected value %s", types.FIL(v))
+	}
+
+	// 全部余额减去最大手续费
+	all := node.pushed[1].Message
+	maxFee := big.Mul(all.GasFeeCap, big.NewInt(all.GasLimit))
+	if !big.Add(all.Value, maxFee).Equals(types.FromFil(10)) {
+		t.Errorf("unexpected sweep value %s with max fee %s", types.FIL(all.Value), types.FIL(maxFee))
+	}
+}
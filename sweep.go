@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/filecoin-project/specs-actors/v5/actors/builtin"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+	"os"
+	"sort"
+)
+
+// 评估gas后将转账金额设置为余额减去最大手续费，金额变化导致gas变化时重新评估
+func prepareSweep(cctx *cli.Context, api v0api.FullNode, msg *types.Message) error {
+	ctx := lcli.ReqContext(cctx)
+
+	balance, err := api.WalletBalance(ctx, msg.From)
+	if err != nil {
+		fmt.Printf("读取余额失败，err:%v\n", err)
+		return err
+	}
+
+	est := *msg
+	est.Value = big.Zero()
+	ret, err := estimateMessageGas(cctx, api, &est)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		maxFee := big.Mul(ret.GasFeeCap, big.NewInt(ret.GasLimit))
+		value := big.Sub(balance, maxFee)
+		if !value.GreaterThan(big.Zero()) {
+			fmt.Printf("余额(%s)不足以支付最大手续费(%s)\n", types.FIL(balance), types.FIL(maxFee))
+			return xerrors.Errorf("balance %s does not cover max fee %s", types.FIL(balance), types.FIL(maxFee))
+		}
+
+		// 使用实际金额重新评估，最大手续费没有增加时即可发送
+		next := *msg
+		next.Value = value
+		re, err := estimateMessageGas(cctx, api, &next)
+		if err != nil {
+			return err
+		}
+
+		if big.Mul(re.GasFeeCap, big.NewInt(re.GasLimit)).LessThanEqual(maxFee) {
+			msg.Value = value
+			msg.GasLimit, msg.GasFeeCap, msg.GasPremium = re.GasLimit, re.GasFeeCap, re.GasPremium
+			return nil
+		}
+		ret = re
+	}
+
+	return xerrors.New("gas estimate did not converge")
+}
+
+var sweepCmd = &cli.Command{
+	Name:  "sweep",
+	Usage: "将匹配标签或角色的所有地址的全部余额（减去最大手续费）转到目标地址",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "转账目标账户",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "label",
+			Usage: "只处理该标签的地址",
+		},
+		&cli.StringFlag{
+			Name:  "role",
+			Usage: "只处理包含该角色的地址",
+		},
+		yesFlag,
+		waitFlag,
+		confidenceFlag,
+	}, gasFlags...),
+	Before: initForSend,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		label, role := cctx.String("label"), cctx.String("role")
+		if label == "" && role == "" {
+			fmt.Println("必须通过--label或--role指定要归集的地址")
+			return xerrors.New("must pass --label or --role")
+		}
+
+		to, err := address.NewFromString(cctx.String("to"))
+		if err != nil {
+			fmt.Printf("解析转账目标地址失败: %v\n", err)
+			return err
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		addrs, err := localdb.GetAll(db.KeyAddr)
+		if err != nil {
+			fmt.Println("读取数据库获取钱包地址失败")
+			return err
+		}
+
+		type sweepSource struct {
+			Addr    address.Address
+			Balance abi.TokenAmount
+		}
+
+		var sources []sweepSource
+		for _, a := range addrs {
+			fai := FilAddressInfo{}
+			if err := json.Unmarshal([]byte(a), &fai); err != nil {
+				continue
+			}
+			if label != "" && fai.Label != label {
+				continue
+			}
+			if role != "" && !hasRole(&fai, role) {
+				continue
+			}
+
+			addr, err := address.NewFromString(fai.Address)
+			if err != nil || addr == to {
+				continue
+			}
+
+			balance, err := api.WalletBalance(ctx, addr)
+			if err != nil {
+				fmt.Printf("读取地址(%s)余额失败，err:%v\n", addr, err)
+				return err
+			}
+			if balance.IsZero() {
+				continue
+			}
+
+			sources = append(sources, sweepSource{Addr: addr, Balance: balance})
+		}
+
+		if len(sources) == 0 {
+			fmt.Println("没有需要归集的地址")
+			return nil
+		}
+
+		sort.Slice(sources, func(i, j int) bool { return sources[i].Addr.String() < sources[j].Addr.String() })
+
+		tw := tablewriter.New(
+			tablewriter.Col("Address"),
+			tablewriter.Col("Balance"))
+		total := big.Zero()
+		for _, s := range sources {
+			tw.Write(map[string]interface{}{
+				"Address": s.Addr,
+				"Balance": types.FIL(s.Balance),
+			})
+			total = big.Add(total, s.Balance)
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+		fmt.Printf("共 %d 个地址，余额合计 %s，转到 %s\n", len(sources), types.FIL(total), to)
+
+		// 输入一次归集地址确认后，每条消息不再单独确认
+		if !cctx.Bool("yes") && !cctx.Bool("dry-run") {
+			if err := confirmMessage(to); err != nil {
+				return err
+			}
+			if err := cctx.Set("yes", "true"); err != nil {
+				return err
+			}
+		}
+
+		var failed int
+		for _, s := range sources {
+			nonce, err := nextNonce(ctx, api, s.Addr)
+			if err != nil {
+				fmt.Printf("获取地址(%s)nonce失败，err:%v\n", s.Addr, err)
+				failed++
+				continue
+			}
+
+			msg := &types.Message{
+				From:   s.Addr,
+				To:     to,
+				Method: builtin.MethodSend,
+				Nonce:  nonce,
+			}
+			if err := prepareSweep(cctx, api, msg); err != nil {
+				failed++
+				continue
+			}
+
+			c, err := sendMessage(cctx, api, msg, address.Undef)
+			if err != nil {
+				failed++
+				continue
+			}
+			if !c.Defined() {
+				continue
+			}
+
+			fmt.Printf("%s -> %s: %s, message %s\n", s.Addr, to, types.FIL(msg.Value), c)
+
+			if cctx.Bool("wait") {
+				if _, err := waitMessage(cctx, api, c); err != nil {
+					failed++
+				}
+			}
+		}
+
+		if failed > 0 {
+			return xerrors.Errorf("%d sweeps failed", failed)
+		}
+		return nil
+	},
+}
+
+func hasRole(fai *FilAddressInfo, role string) bool {
+	for _, r := range fai.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}